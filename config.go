@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+// NamedPattern is a pattern defined in config file
+type NamedPattern struct {
+	Pattern     string  `toml:"pattern"`
+	Description string  `toml:"description"`
+	MinEntropy  float64 `toml:"min_entropy"`
+}
+
+// Config is the content of config file, for example:
+//
+//	[patterns.wifi]
+//	pattern = "[:alnum:]{20}"
+//	description = "Wi-Fi key"
+//	min_entropy = 100
+type Config struct {
+	Patterns map[string]*NamedPattern `toml:"patterns"`
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "repassgen", "patterns.toml")
+}
+
+// usesConfig returns true if positional argument uses named patterns of
+// config file, as "@name" or "$use(name)"
+func usesConfig(arg string) bool {
+	return strings.HasPrefix(arg, "@") || strings.Contains(arg, "$use(")
+}
+
+// loadConfig reads and parses config file, and registers its patterns
+// to be used as $use(name) in patterns
+// every pattern is compiled with the options of input, so that errors
+// are reported with the name of pattern, even if it's not used
+// if required is false, missing file is not an error
+func loadConfig(path string, required bool, input passgen.GenerateInput) (*Config, error) {
+	conf := &Config{}
+	if path == "" {
+		return conf, nil
+	}
+	_, err := toml.DecodeFile(path, conf)
+	if err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return conf, nil
+		}
+		return nil, err
+	}
+	for _, name := range conf.patternNames() {
+		np := conf.Patterns[name]
+		if np == nil || np.Pattern == "" {
+			return nil, fmt.Errorf("%s: pattern %#v is empty", path, name)
+		}
+		err := passgen.OverridePattern(name, np.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	// patterns are compiled after all of them are registered, since
+	// they can use each other
+	for _, name := range conf.patternNames() {
+		input.Pattern = []rune(conf.Patterns[name].Pattern)
+		_, err := passgen.CompileInput(input)
+		if err != nil {
+			return nil, fmt.Errorf("%s: pattern %#v: %w", path, name, err)
+		}
+	}
+	return conf, nil
+}
+
+func (conf *Config) patternNames() []string {
+	names := make([]string, 0, len(conf.Patterns))
+	for name := range conf.Patterns {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// resolvePattern returns the pattern of given positional argument
+// "@name" refers to a named pattern of config file
+// "\@" at the beginning can be used for a pattern that starts with "@"
+func (conf *Config) resolvePattern(arg string) (string, *NamedPattern, error) {
+	name, ok := strings.CutPrefix(arg, "@")
+	if !ok {
+		return arg, nil, nil
+	}
+	np := conf.Patterns[name]
+	if np == nil {
+		return "", nil, fmt.Errorf("unknown pattern name %#v", name)
+	}
+	return np.Pattern, np, nil
+}
+
+func (np *NamedPattern) checkEntropy(p *passgen.Pattern) error {
+	if p.Entropy() < np.MinEntropy {
+		return fmt.Errorf(
+			"entropy of pattern is %.2f bits, less than minimum of %v bits",
+			p.Entropy(),
+			np.MinEntropy,
+		)
+	}
+	return nil
+}
+
+func printPatterns(w io.Writer, conf *Config) error {
+	for _, name := range conf.patternNames() {
+		np := conf.Patterns[name]
+		line := "@" + name + "\t" + np.Pattern
+		if np.Description != "" {
+			line += "\t" + np.Description
+		}
+		_, err := io.WriteString(w, line+"\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ module github.com/ilius/repassgen
 go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/ilius/bip39-coder v0.0.0-20241206173118-1ab674f2290f
 	github.com/ilius/is/v2 v2.4.0
 	github.com/ilius/libgostarcal v1.0.0
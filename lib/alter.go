@@ -1,78 +1,161 @@
 package passgen
 
 import (
-	"crypto/rand"
+	"fmt"
 	"math"
-	"math/big"
 )
 
 type alterGenerator struct {
-	entropy   *float64
+	roots     []*RootGenerator
 	parts     [][]rune
 	indexList []uint64
 	length    uint64
+	groupId   uint64
+	pattern   []rune
+
+	// entropyList is the list of entropy of branches
+	entropyList []float64
 }
 
-func (g *alterGenerator) calcMinEntropy(s *State) (float64, error) {
-	// TODO: optimize
-	minEntropy := 0.0
-	for partI, part := range g.parts {
+func (g *alterGenerator) compile(s *State) error {
+	parts := g.parts
+	// randInt panics if the arg is zero, which means len(parts) == 0
+	if len(parts) == 0 {
+		return s.errorSyntax("no arguments in alteration")
+	}
+	roots := make([]*RootGenerator, len(parts))
+	lastGroupId := s.lastGroupId
+	for partI, part := range parts {
 		s2 := NewState(s.SharedState.Copy(), part)
 		s2.errorOffset += int64(g.indexList[partI] - g.length)
-		s2.patternEntropy = 0
-		_, err := subGenerate(s2, part)
+		// groups of branches are numbered from left to right
+		s2.lastGroupId = lastGroupId
+		root, err := subCompile(s2, part)
 		if err != nil {
-			return 0, err
+			return err
 		}
-		entropy := s2.patternEntropy
-		if entropy == 0 {
-			return 0, nil
-		}
-		if partI == 0 || entropy < minEntropy {
-			minEntropy = entropy
+		roots[partI] = root
+		lastGroupId = s2.lastGroupId
+	}
+	entropyList := make([]float64, len(roots))
+	for i, root := range roots {
+		entropy, err := root.Entropy(s)
+		if err != nil {
+			return err
 		}
+		entropyList[i] = entropy
 	}
-	return minEntropy, nil
+	g.roots = roots
+	g.entropyList = entropyList
+	s.lastGroupId = lastGroupId
+	return nil
 }
 
-func (g *alterGenerator) Generate(s *State) error {
-	parts := g.parts
-	// rand.Int panics if the arg is zero, which means len(parts) == 0
-	if len(parts) == 0 {
-		return s.errorSyntax("no arguments in alteration")
+// minEntropy returns log2(n) + minimum entropy of branches
+// which is a conservative value when branches are chosen with equal probability
+func (g *alterGenerator) minEntropy() float64 {
+	minEntropy := g.entropyList[0]
+	for _, entropy := range g.entropyList[1:] {
+		minEntropy = min(minEntropy, entropy)
+	}
+	return math.Log2(float64(len(g.entropyList))) + minEntropy
+}
+
+// keyspaceEntropy returns log2(sum(2^H_i)) where H_i is entropy of branch i
+// which is the Shannon entropy when branches are weighted by keyspace size
+func (g *alterGenerator) keyspaceEntropy() float64 {
+	maxEntropy := g.entropyList[0]
+	for _, entropy := range g.entropyList[1:] {
+		maxEntropy = max(maxEntropy, entropy)
+	}
+	sum := 0.0
+	for _, entropy := range g.entropyList {
+		sum += math.Exp2(entropy - maxEntropy)
+	}
+	return maxEntropy + math.Log2(sum)
+}
+
+// branchIndex returns index of a random branch
+// if s.uniform is true, probability of each branch is proportional to
+// its keyspace size, otherwise all branches have the same probability
+func (g *alterGenerator) branchIndex(s *State) (int, error) {
+	if s.uniform {
+		return s.randWeighted(g.entropyList)
 	}
-	ibig, err := rand.Int(rand.Reader, big.NewInt(int64(len(parts))))
+	i, err := s.randInt(int64(len(g.roots)))
 	if err != nil {
-		panic(err) // not sure how to trigger this in test
+		return 0, err
 	}
+	return int(i), nil
+}
 
-	i := ibig.Int64()
-	groupId := s.lastGroupId
-	s2 := NewState(s.SharedState.Copy(), parts[i])
-	s2.errorOffset += int64(g.indexList[i] - g.length)
-	output, err := subGenerate(s2, parts[i])
+func (g *alterGenerator) Generate(s *State) error {
+	if g.roots == nil {
+		err := g.compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	i, err := g.branchIndex(s)
 	if err != nil {
 		return err
 	}
-	s.output = append(s.output, output...)
-
-	minEntropy, err := g.calcMinEntropy(s)
+	start := len(s.output)
+	err = g.roots[i].Generate(s)
 	if err != nil {
 		return err
 	}
-
-	s.patternEntropy += math.Log2(float64(len(parts)))
-	s.patternEntropy += minEntropy
-	g.entropy = &s.patternEntropy
-
-	s.lastGroupId = s2.lastGroupId
-	s.groupsOutput[groupId] = output
+	if g.groupId > 0 {
+		s.groupsOutput[g.groupId] = s.output[start:]
+	}
 	return nil
 }
 
+// Entropy returns the keyspace entropy if s.uniform is true, and
+// the minimum entropy otherwise, both are calculated statically from
+// entropy of branches, see minEntropy and keyspaceEntropy
 func (g *alterGenerator) Entropy(s *State) (float64, error) {
-	if g.entropy != nil {
-		return *g.entropy, nil
+	if g.roots == nil {
+		return 0, s.errorUnknown(s_entropy_not_calc)
+	}
+	if s.uniform {
+		return g.keyspaceEntropy(), nil
+	}
+	return g.minEntropy(), nil
+}
+
+func (g *alterGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	if g.roots == nil {
+		return nil, s.errorUnknown(s_entropy_not_calc)
+	}
+	entropy, err := g.Entropy(s)
+	if err != nil {
+		return nil, err
+	}
+	detail := fmt.Sprintf(
+		"log2(%d) + minimum entropy of branches, keyspace entropy is %.2f bits",
+		len(g.roots),
+		g.keyspaceEntropy(),
+	)
+	if s.uniform {
+		detail = fmt.Sprintf(
+			"log2(sum(2^entropy)) of branches, minimum entropy is %.2f bits",
+			g.minEntropy(),
+		)
+	}
+	node := &EntropyNode{
+		Kind:    EntropyKindAlteration,
+		Pattern: string(g.pattern),
+		Detail:  detail,
+		Size:    len(g.roots),
+		Entropy: entropy,
+	}
+	for _, root := range g.roots {
+		childNode, err := root.explainEntropy(s)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, childNode)
 	}
-	return 0, s.errorUnknown(s_entropy_not_calc)
+	return node, nil
 }
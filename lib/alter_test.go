@@ -44,3 +44,22 @@ func TestAlterGeneratorEmpty(t *testing.T) {
 		is.ErrMsg(err, "syntax error near index 0: no arguments in alteration")
 	}
 }
+
+func TestAlterEntropyUniform(t *testing.T) {
+	is := is.New(t)
+	entropy := func(pattern string, uniform bool) float64 {
+		p, err := passgen.CompileInput(passgen.GenerateInput{
+			Pattern: []rune(pattern),
+			Uniform: uniform,
+		})
+		is.NotErr(err)
+		return p.Entropy()
+	}
+	// log2(2) + min(0, 20*log2(62))
+	isFloatBetween(is, entropy(`(a|[:alnum:]{20})`, false), 1, 1)
+	// log2(1 + 62^20)
+	isFloatBetween(is, entropy(`(a|[:alnum:]{20})`, true), 119.08, 119.09)
+	// log2(10 + 100)
+	isFloatBetween(is, entropy(`([0-9]|[0-9]{2})`, true), 6.78, 6.79)
+	isFloatBetween(is, entropy(`(a|b|c|d)`, true), 2, 2)
+}
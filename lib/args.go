@@ -10,6 +10,9 @@ type ArgsParser struct {
 	openCurly   bool
 	backslash   bool
 	sep         rune
+
+	// nestedBrackets is the number of open nested [...] inside character class
+	nestedBrackets int
 }
 
 func (p *ArgsParser) add(c rune) {
@@ -35,8 +38,15 @@ func (p *ArgsParser) checkBackslash(c rune) bool {
 
 func (p *ArgsParser) checkBrackets(c rune) bool {
 	if p.openBracket {
-		if c == ']' {
-			p.openBracket = false
+		switch c {
+		case '[':
+			p.nestedBrackets++
+		case ']':
+			if p.nestedBrackets > 0 {
+				p.nestedBrackets--
+			} else {
+				p.openBracket = false
+			}
 		}
 		p.add(c)
 		return true
@@ -1,10 +1,9 @@
 package passgen
 
 import (
-	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"math"
-	"math/big"
 	"strconv"
 	"strings"
 
@@ -28,11 +27,11 @@ func (g *bip39WordGenerator) Generate(s *State) error {
 	count := g.wordCount
 	words := make([]string, count)
 	for ai := range count {
-		ibig, err := rand.Int(rand.Reader, big.NewInt(int64(bip39.WordCount())))
+		i, err := s.randInt(int64(bip39.WordCount()))
 		if err != nil {
-			panic(err)
+			return err
 		}
-		index := int(ibig.Int64())
+		index := int(i)
 		word, ok := bip39.GetWord(index)
 		if !ok {
 			return s.errorUnknown("internal error, index=%v > 2048", index)
@@ -41,12 +40,7 @@ func (g *bip39WordGenerator) Generate(s *State) error {
 	}
 	result := []rune(strings.Join(words, " "))
 
-	s.addOutputNonRepeatable(result)
-	entropy, err := g.Entropy(s)
-	if err != nil {
-		return err
-	}
-	s.patternEntropy += entropy
+	s.addOutput(result)
 	return nil
 }
 
@@ -70,3 +64,22 @@ func newBIP39WordGenerator(s *State, arg string) (*bip39WordGenerator, error) {
 		wordCount: argInt64,
 	}, nil
 }
+
+func (g *bip39WordGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	entropy, err := g.Entropy(s)
+	if err != nil {
+		return nil, err
+	}
+	wordCount := bip39.WordCount()
+	return &EntropyNode{
+		Detail: fmt.Sprintf(
+			"%d words of %d, %.0f bits per word",
+			g.wordCount,
+			wordCount,
+			math.Log2(float64(wordCount)),
+		),
+		Size:    wordCount,
+		Count:   g.wordCount,
+		Entropy: entropy,
+	}, nil
+}
@@ -1,31 +1,54 @@
 package passgen
 
 import (
-	"crypto/rand"
 	"encoding/hex"
-	"math/big"
+	"fmt"
+	"math"
 	"strings"
 )
 
 type byteGenerator struct {
 	uppercase bool
+
+	// digits is the hex digits that are not excluded, nil if none of
+	// hex digits are excluded
+	digits []rune
 }
 
 func (g *byteGenerator) Generate(s *State) error {
-	randBig, err := rand.Int(rand.Reader, big.NewInt(0xff))
+	if g.digits != nil {
+		return g.generateDigits(s)
+	}
+	b, err := s.randInt(0xff)
 	if err != nil {
-		panic(err) // not sure how to trigger this in test
+		return err
 	}
-	byteStr := hex.EncodeToString([]byte{uint8(randBig.Uint64())})
+	byteStr := hex.EncodeToString([]byte{uint8(b)})
 	if g.uppercase {
 		byteStr = strings.ToUpper(byteStr)
 	}
-	s.addOutputNonRepeatable([]rune(byteStr))
-	s.patternEntropy += 8
+	s.addOutput([]rune(byteStr))
+	return nil
+}
+
+// generateDigits generates 2 hex digits from digits that are not excluded
+func (g *byteGenerator) generateDigits(s *State) error {
+	output := make([]rune, 2)
+	for i := range output {
+		index, err := s.randInt(int64(len(g.digits)))
+		if err != nil {
+			return err
+		}
+		output[i] = g.digits[index]
+	}
+	s.addOutput(output)
 	return nil
 }
 
 func (g *byteGenerator) Entropy(_ *State) (float64, error) {
+	if g.digits != nil {
+		return 2 * math.Log2(float64(len(g.digits))), nil
+	}
 	return 8, nil
 }
 
@@ -34,5 +57,34 @@ func newByteGenerator(s *State, argsStr []rune, uppercase bool) (*byteGenerator,
 		s.errorOffset += 1
 		return nil, s.errorValue("function does not accept any arguments")
 	}
-	return &byteGenerator{uppercase: uppercase}, nil
+	g := &byteGenerator{uppercase: uppercase}
+	if len(s.exclude) > 0 {
+		hexDigits := "0123456789abcdef"
+		if uppercase {
+			hexDigits = "0123456789ABCDEF"
+		}
+		digits := subtractRunes([]rune(hexDigits), s.exclude)
+		if len(digits) == 0 {
+			s.errorOffset += 1
+			return nil, s.errorValue("all hex digits are excluded")
+		}
+		if len(digits) < len(hexDigits) {
+			g.digits = digits
+		}
+	}
+	return g, nil
+}
+
+func (g *byteGenerator) explainEntropy(_ *State) (*EntropyNode, error) {
+	if g.digits != nil {
+		return &EntropyNode{
+			Detail:  fmt.Sprintf("2 hex digits out of %d allowed digits", len(g.digits)),
+			Size:    len(g.digits),
+			Entropy: 2 * math.Log2(float64(len(g.digits))),
+		}, nil
+	}
+	return &EntropyNode{
+		Detail:  "1 random byte",
+		Entropy: 8,
+	}, nil
 }
@@ -1,14 +1,14 @@
 package passgen
 
 import (
-	"crypto/rand"
+	"fmt"
 	"math"
-	"math/big"
 )
 
 type charClassGenerator struct {
 	entropy     *float64
 	charClasses [][]rune
+	pattern     []rune
 }
 
 func (g *charClassGenerator) Generate(s *State) error {
@@ -16,15 +16,12 @@ func (g *charClassGenerator) Generate(s *State) error {
 		if len(chars) == 0 {
 			continue
 		}
-		ibig, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		i, err := s.randInt(int64(len(chars)))
 		if err != nil {
-			panic(err) // not sure how to trigger this in test
+			return err
 		}
-		i := int(ibig.Int64())
 		s.output = append(s.output, chars[i])
 	}
-	entropy := g.getEntropy()
-	s.patternEntropy += entropy
 	return nil
 }
 
@@ -46,3 +43,16 @@ func (g *charClassGenerator) getEntropy() float64 {
 func (g *charClassGenerator) Entropy(_ *State) (float64, error) {
 	return g.getEntropy(), nil
 }
+
+func (g *charClassGenerator) explainEntropy(_ *State) (*EntropyNode, error) {
+	node := &EntropyNode{
+		Kind:    EntropyKindCharClass,
+		Pattern: string(g.pattern),
+		Entropy: g.getEntropy(),
+	}
+	if len(g.charClasses) == 1 {
+		node.Size = len(g.charClasses[0])
+		node.Detail = fmt.Sprintf("%d characters", node.Size)
+	}
+	return node, nil
+}
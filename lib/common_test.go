@@ -56,5 +56,10 @@ type genCase struct {
 type genErrCase struct {
 	Pattern string
 
+	// Exclude, AllowFiles and FileDir are passed to passgen.GenerateInput
+	Exclude    string
+	AllowFiles bool
+	FileDir    string
+
 	Error any
 }
@@ -1,9 +1,8 @@
 package passgen
 
 import (
-	"crypto/rand"
+	"fmt"
 	"math"
-	"math/big"
 	"strconv"
 	"strings"
 
@@ -20,15 +19,14 @@ type dateGenerator struct {
 func (g *dateGenerator) Generate(s *State) error {
 	startJd := g.startJd
 	endJd := g.endJd
-	randBig, err := rand.Int(rand.Reader, big.NewInt(int64(endJd-startJd)))
+	i, err := s.randInt(int64(endJd - startJd))
 	if err != nil {
-		panic(err) // not sure how to trigger this in test
+		return err
 	}
-	jd := startJd + int(randBig.Int64())
+	jd := startJd + int(i)
 	date := gregorian.JdTo(jd)
 	dateStr := date.StringWithSep(g.sep)
-	s.addOutputNonRepeatable([]rune(dateStr))
-	s.patternEntropy += g.entropy()
+	s.addOutput([]rune(dateStr))
 	return nil
 }
 
@@ -77,3 +75,12 @@ func newDateGenerator(s *State, argsStr []rune) (*dateGenerator, error) {
 		sep:     sep,
 	}, nil
 }
+
+func (g *dateGenerator) explainEntropy(_ *State) (*EntropyNode, error) {
+	days := g.endJd - g.startJd
+	return &EntropyNode{
+		Detail:  fmt.Sprintf("%d days", days),
+		Size:    days,
+		Entropy: g.entropy(),
+	}, nil
+}
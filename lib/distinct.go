@@ -0,0 +1,296 @@
+package passgen
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// charSetCount is a character set of output, with the number of positions
+// of output that are chosen from it
+type charSetCount struct {
+	chars []rune
+	count int64
+}
+
+// charSetCounts returns the character sets of output of root with the
+// number of positions of each one, without expanding repetitions, if root
+// is a sequence of static strings, char classes, non-capturing groups and
+// fixed repetitions of them, otherwise returns false
+func charSetCounts(root *RootGenerator) ([]charSetCount, bool) {
+	sets := []charSetCount{}
+	var collect func(gen GeneratorIface, count int64) bool
+	collect = func(gen GeneratorIface, count int64) bool {
+		switch g := gen.(type) {
+		case *staticStringGenerator:
+			for _, c := range g.str {
+				sets = append(sets, charSetCount{chars: []rune{c}, count: count})
+			}
+			return true
+		case *charClassGenerator:
+			for _, chars := range g.charClasses {
+				if len(chars) > 0 {
+					sets = append(sets, charSetCount{chars: chars, count: count})
+				}
+			}
+			return true
+		case *groupGenerator:
+			// output of capturing groups is used by group references
+			if g.groupId > 0 {
+				return false
+			}
+			for _, child := range g.root.children {
+				if !collect(child, count) {
+					return false
+				}
+			}
+			return true
+		case *repeatGenerator:
+			if g.minCount != g.maxCount {
+				return false
+			}
+			return collect(g.child, satMul(count, g.minCount))
+		}
+		return false
+	}
+	for _, child := range root.children {
+		if !collect(child, 1) {
+			return nil, false
+		}
+	}
+	return sets, true
+}
+
+// checkSetsLength returns the length of output with given character sets,
+// or error if it is longer than maxOutputLength (unless output is truncated),
+// so that repetitions are checked before they are expanded by charSlots
+func checkSetsLength(s *State, sets []charSetCount) (int64, error) {
+	length := int64(0)
+	for _, set := range sets {
+		length = satAdd(length, set.count)
+	}
+	if s.maxOutputLength > 0 && !s.truncateOutput && length > int64(s.maxOutputLength) {
+		return 0, fmt.Errorf("password is longer than %d characters", s.maxOutputLength)
+	}
+	return length, nil
+}
+
+// charSlots returns the list of character sets of every position of
+// output of root, if root is a sequence of static strings, char classes,
+// non-capturing groups and fixed repetitions of them, otherwise returns false
+func charSlots(root *RootGenerator) ([][]rune, bool) {
+	slots := [][]rune{}
+	var collect func(gen GeneratorIface) bool
+	collect = func(gen GeneratorIface) bool {
+		switch g := gen.(type) {
+		case *staticStringGenerator:
+			for _, c := range g.str {
+				slots = append(slots, []rune{c})
+			}
+			return true
+		case *charClassGenerator:
+			for _, chars := range g.charClasses {
+				if len(chars) > 0 {
+					slots = append(slots, chars)
+				}
+			}
+			return true
+		case *groupGenerator:
+			// output of capturing groups is used by group references
+			if g.groupId > 0 {
+				return false
+			}
+			for _, child := range g.root.children {
+				if !collect(child) {
+					return false
+				}
+			}
+			return true
+		case *repeatGenerator:
+			if g.minCount != g.maxCount {
+				return false
+			}
+			start := len(slots)
+			if !collect(g.child) {
+				return false
+			}
+			childSlots := slots[start:]
+			for range g.minCount - 1 {
+				slots = append(slots, childSlots...)
+			}
+			return true
+		}
+		return false
+	}
+	for _, child := range root.children {
+		if !collect(child) {
+			return nil, false
+		}
+	}
+	return slots, true
+}
+
+// distinctGenerator is the generator of $distinct(PATTERN) that generates
+// PATTERN with all characters unique, by sampling characters of each
+// character class without replacement
+type distinctGenerator struct {
+	root       *RootGenerator
+	argPattern []rune
+
+	// slots is the list of positions of output, each one is either a
+	// fixed character (group is -1) or index of a group in groups
+	slots []distinctSlot
+	// groups is the list of character sets that positions are chosen from
+	// with fixed characters removed, which are identical or disjoint
+	groups [][]rune
+
+	entropy float64
+}
+
+type distinctSlot struct {
+	char  rune
+	group int
+}
+
+func newDistinctGenerator(arg []rune) (*distinctGenerator, error) {
+	return &distinctGenerator{
+		argPattern: arg,
+	}, nil
+}
+
+func (g *distinctGenerator) compile(s *State) error {
+	root, err := subCompile(s, g.argPattern)
+	if err != nil {
+		return err
+	}
+	s.errorMarkLen = max(len(g.argPattern), 1)
+	sets, ok := charSetCounts(root)
+	if !ok {
+		return s.errorValue("distinct: argument must only have characters, character classes, non-capturing groups and fixed repetitions")
+	}
+	_, err = checkSetsLength(s, sets)
+	if err != nil {
+		return err
+	}
+	fixed := []rune{}
+	for _, set := range sets {
+		if len(set.chars) != 1 {
+			continue
+		}
+		if set.count > 1 || slices.Contains(fixed, set.chars[0]) {
+			return s.errorValue("distinct: character %q is repeated", set.chars[0])
+		}
+		fixed = append(fixed, set.chars[0])
+	}
+	groups := [][]rune{}
+	groupByKey := map[string]int{}
+	groupByChar := map[rune]int{}
+	// groupBySet maps the first character of every character set of
+	// argument to index of its group, which is also used for expanded slots
+	groupBySet := map[*rune]int{}
+	counts := []int64{}
+	for _, set := range sets {
+		if len(set.chars) == 1 {
+			continue
+		}
+		chars := subtractRunes(set.chars, fixed)
+		sorted := slices.Clone(chars)
+		slices.Sort(sorted)
+		key := string(sorted)
+		index, found := groupByKey[key]
+		if !found {
+			index = len(groups)
+			for _, c := range chars {
+				if _, overlap := groupByChar[c]; overlap {
+					return s.errorValue("distinct: character classes of argument must be identical or disjoint")
+				}
+				groupByChar[c] = index
+			}
+			groupByKey[key] = index
+			groups = append(groups, chars)
+			counts = append(counts, 0)
+		}
+		counts[index] = satAdd(counts[index], set.count)
+		groupBySet[&set.chars[0]] = index
+	}
+	// log2 of number of k-permutations of each group: n! / (n-k)!
+	entropy := 0.0
+	for i, chars := range groups {
+		n, k := int64(len(chars)), counts[i]
+		if k > n {
+			return s.errorValue("distinct: %d distinct characters are needed from a class of %d characters", k, n)
+		}
+		lgammaN, _ := math.Lgamma(float64(n + 1))
+		lgammaNK, _ := math.Lgamma(float64(n - k + 1))
+		entropy += (lgammaN - lgammaNK) / math.Ln2
+	}
+	// output is not longer than the number of distinct characters now
+	charSets, _ := charSlots(root)
+	slots := make([]distinctSlot, len(charSets))
+	for i, chars := range charSets {
+		if len(chars) == 1 {
+			slots[i] = distinctSlot{char: chars[0], group: -1}
+			continue
+		}
+		slots[i] = distinctSlot{group: groupBySet[&chars[0]]}
+	}
+	g.root = root
+	g.slots = slots
+	g.groups = groups
+	g.entropy = entropy
+	return nil
+}
+
+func (g *distinctGenerator) Generate(s *State) error {
+	if g.root == nil {
+		err := g.compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	remaining := make([][]rune, len(g.groups))
+	for i, chars := range g.groups {
+		remaining[i] = slices.Clone(chars)
+	}
+	output := make([]rune, len(g.slots))
+	for i, slot := range g.slots {
+		if slot.group < 0 {
+			output[i] = slot.char
+			continue
+		}
+		chars := remaining[slot.group]
+		j, err := s.randInt(int64(len(chars)))
+		if err != nil {
+			return err
+		}
+		output[i] = chars[j]
+		chars[j] = chars[len(chars)-1]
+		remaining[slot.group] = chars[:len(chars)-1]
+	}
+	s.addOutput(output)
+	return nil
+}
+
+// Entropy returns log2 of number of passwords that argument can generate
+// with all characters unique, which are equally likely
+func (g *distinctGenerator) Entropy(s *State) (float64, error) {
+	if g.root == nil {
+		return 0, s.errorUnknown(s_entropy_not_calc)
+	}
+	return g.entropy, nil
+}
+
+func (g *distinctGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	if g.root == nil {
+		return nil, s.errorUnknown(s_entropy_not_calc)
+	}
+	argNode, err := g.root.explainEntropy(s)
+	if err != nil {
+		return nil, err
+	}
+	return &EntropyNode{
+		Detail:   "log2 of number of outputs of argument with all characters unique",
+		Entropy:  g.entropy,
+		Children: []*EntropyNode{argNode},
+	}, nil
+}
@@ -0,0 +1,75 @@
+package passgen_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestDistinct(t *testing.T) {
+	is := is.New(t)
+	test := func(pattern string, length int, entropy float64) {
+		is := is.AddMsg("pattern=%#v", pattern)
+		p, err := passgen.Compile([]rune(pattern))
+		is.NotErr(err)
+		is.True(math.Abs(p.Entropy()-entropy) < 0.0001)
+		for range 20 {
+			out, err := p.Generate()
+			is.NotErr(err)
+			is.Equal(len(out.Password), length)
+			seen := map[rune]bool{}
+			for _, c := range out.Password {
+				is.AddMsg("password=%#v", string(out.Password)).False(seen[c])
+				seen[c] = true
+			}
+		}
+	}
+	test(`$distinct([:digit:]{4})`, 4, math.Log2(10*9*8*7))
+	test(`$distinct([:digit:]{10})`, 10, math.Log2(3628800))
+	test(`$distinct([:upper:]{2}[:digit:]{3})`, 5, math.Log2(26*25*10*9*8))
+	test(`$distinct((?:[:upper:][:digit:]){2})`, 4, math.Log2(26*25*10*9))
+	// fixed characters are not chosen for character classes
+	test(`$distinct(a[a-c]{2})`, 3, 1)
+	test(`$distinct(x[:alpha:]{3})`, 4, math.Log2(51*50*49))
+}
+
+func TestDistinctError(t *testing.T) {
+	testGenErr(t, &genErrCase{
+		Pattern: `$distinct([ab]{3})`,
+		Error:   `          ^^^^^^^ value error: distinct: 3 distinct characters are needed from a class of 2 characters`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$distinct(-[:alpha:]{3}-x)`,
+		Error:   `          ^^^^^^^^^^^^^^^ value error: distinct: character '-' is repeated`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$distinct([a-c][b-d])`,
+		Error:   `          ^^^^^^^^^^ value error: distinct: character classes of argument must be identical or disjoint`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$distinct([a-z]{20000000})`,
+		Error:   `          ^^^^^^^^^^^^^^^ value error: distinct: 20000000 distinct characters are needed from a class of 26 characters`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$distinct((?:ab){100000000})`,
+		Error:   `          ^^^^^^^^^^^^^^^^^ value error: distinct: character 'a' is repeated`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$distinct(abca)`,
+		Error:   `          ^^^^ value error: distinct: character 'a' is repeated`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$distinct([a-z]{1,3})`,
+		Error:   `          ^^^^^^^^^^ value error: distinct: argument must only have characters, character classes, non-capturing groups and fixed repetitions`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$distinct(x(a|b))`,
+		Error:   `          ^^^^^^ value error: distinct: argument must only have characters, character classes, non-capturing groups and fixed repetitions`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$distinct(([a-z]))`,
+		Error:   `          ^^^^^^^ value error: distinct: argument must only have characters, character classes, non-capturing groups and fixed repetitions`,
+	})
+}
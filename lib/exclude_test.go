@@ -0,0 +1,67 @@
+package passgen_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestGenerateExclude(t *testing.T) {
+	is := is.New(t)
+	test := func(pattern string, exclude string, entropy float64, check func(rune) bool) {
+		is := is.AddMsg("pattern=%#v, exclude=%#v", pattern, exclude)
+		for range 10 {
+			out, _, err := passgen.Generate(passgen.GenerateInput{
+				Pattern: []rune(pattern),
+				Exclude: []rune(exclude),
+			})
+			is.NotErr(err)
+			is.True(math.Abs(out.PatternEntropy-entropy) < 0.0001)
+			for _, c := range out.Password {
+				is.AddMsg("char=%#v", string(c)).True(check(c))
+			}
+		}
+	}
+	notIn := func(chars string) func(rune) bool {
+		return func(c rune) bool {
+			return !strings.ContainsRune(chars, c)
+		}
+	}
+	amb := passgen.AmbiguousChars
+	test(`[:alnum:]{10}`, amb, 10*math.Log2(62-5), notIn(amb))
+	test(`\w{10}`, amb, 10*math.Log2(63-5), notIn(amb))
+	test(`[:graph:]{10}`, amb, 10*math.Log2(94-9), notIn(amb))
+	test(`[a-z]{10}`, "lxyz", 10*math.Log2(22), notIn("lxyz"))
+	test(`[^a-z]{10}`, amb, 10*math.Log2(95-26-8), notIn(amb))
+	test(`[[:alnum:]--[a-z]]{10}`, amb, 10*math.Log2(36-4), notIn(amb))
+	test(`$shuffle([:digit:]{4})`, "01", 4*math.Log2(8), notIn("01"))
+	test(`$byte(){4}`, "0a", 4*2*math.Log2(14), notIn("0a"))
+	test(`$BYTE(){4}`, "0a", 4*2*math.Log2(15), notIn("0a"))
+	// lowercase hex digits are not used by $BYTE()
+	test(`$BYTE()`, "abcdef", 8, notIn("abcdef"))
+	// literal characters are not removed
+	test(`l[a-z]{2}`, "l", math.Log2(25)*2, func(c rune) bool {
+		return c >= 'a' && c <= 'z'
+	})
+}
+
+func TestGenerateExcludeError(t *testing.T) {
+	testGenErr(t, &genErrCase{
+		Pattern: `abc[01]{4}`,
+		Exclude: "01",
+		Error:   `   ^^^^ value error: all characters of class are excluded`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `\d`,
+		Exclude: "0123456789",
+		Error:   `^^ value error: all characters of class are excluded`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `x$byte()`,
+		Exclude: "0123456789abcdef",
+		Error:   `       ^ value error: all hex digits are excluded`,
+	})
+}
@@ -0,0 +1,86 @@
+package passgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// kinds of EntropyNode
+const (
+	EntropyKindSequence   = "sequence"
+	EntropyKindLiteral    = "literal"
+	EntropyKindCharClass  = "charclass"
+	EntropyKindRepeat     = "repeat"
+	EntropyKindGroup      = "group"
+	EntropyKindGroupRef   = "backref"
+	EntropyKindAlteration = "alteration"
+	EntropyKindFunction   = "function"
+	EntropyKindGenerator  = "generator"
+	EntropyKindPolicy     = "policy"
+)
+
+// EntropyNode is a node in the entropy breakdown of a pattern
+// see Pattern.ExplainEntropy
+type EntropyNode struct {
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern"`
+	// Detail is a human-readable explanation of how Entropy is calculated
+	Detail string `json:"detail,omitempty"`
+	// Size is the number of choices, like the alphabet size of a char class
+	// or the number of branches of an alteration
+	Size int `json:"size,omitempty"`
+	// Count is the number of times that children are repeated
+	Count int64 `json:"count,omitempty"`
+
+	Entropy  float64        `json:"entropy"`
+	Children []*EntropyNode `json:"children,omitempty"`
+}
+
+// String returns the text rendering of entropy tree
+func (n *EntropyNode) String() string {
+	sb := &strings.Builder{}
+	n.write(sb, 0)
+	return sb.String()
+}
+
+func (n *EntropyNode) write(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(sb, "%s %q", n.Kind, n.Pattern)
+	if n.Detail != "" {
+		sb.WriteString(", " + n.Detail)
+	}
+	fmt.Fprintf(sb, ": %.2f bits\n", n.Entropy)
+	for _, child := range n.Children {
+		child.write(sb, depth+1)
+	}
+}
+
+// entropyExplainerIface is implemented by generators that can explain
+// how their entropy is calculated
+type entropyExplainerIface interface {
+	explainEntropy(s *State) (*EntropyNode, error)
+}
+
+func explainGenerator(s *State, gen GeneratorIface) (*EntropyNode, error) {
+	if explainer, ok := gen.(entropyExplainerIface); ok {
+		return explainer.explainEntropy(s)
+	}
+	entropy, err := gen.Entropy(s)
+	if err != nil {
+		return nil, err
+	}
+	return &EntropyNode{
+		Kind:    EntropyKindGenerator,
+		Entropy: entropy,
+	}, nil
+}
+
+// ExplainEntropy returns the tree of entropy breakdown of pattern
+func (p *Pattern) ExplainEntropy() (*EntropyNode, error) {
+	ss := NewSharedState()
+	ss.uniform = p.uniform
+	ss.universe = p.universe
+	ss.exclude = p.exclude
+	ss.charClasses = p.charClasses
+	return p.root.explainEntropy(NewState(ss, p.pattern))
+}
@@ -0,0 +1,99 @@
+package passgen_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestExplainEntropy(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`([a-z]{5}[1-9]{2})-\1`))
+	is.NotErr(err)
+	node, err := p.ExplainEntropy()
+	is.NotErr(err)
+	is.Equal(passgen.EntropyKindSequence, node.Kind)
+	is.Equal(p.Entropy(), node.Entropy)
+	is.Equal(3, len(node.Children))
+
+	group := node.Children[0]
+	is.Equal(passgen.EntropyKindGroup, group.Kind)
+	is.Equal(`([a-z]{5}[1-9]{2})`, group.Pattern)
+	is.Equal(2, len(group.Children))
+
+	repeat := group.Children[0]
+	is.Equal(passgen.EntropyKindRepeat, repeat.Kind)
+	is.Equal(`[a-z]{5}`, repeat.Pattern)
+	is.Equal(int64(5), repeat.Count)
+	isFloatBetween(is, repeat.Entropy, 23.5, 23.6)
+
+	charClass := repeat.Children[0]
+	is.Equal(passgen.EntropyKindCharClass, charClass.Kind)
+	is.Equal(`[a-z]`, charClass.Pattern)
+	is.Equal(26, charClass.Size)
+
+	is.Equal(passgen.EntropyKindLiteral, node.Children[1].Kind)
+	is.Equal("-", node.Children[1].Pattern)
+	is.Equal(passgen.EntropyKindGroupRef, node.Children[2].Kind)
+	is.Equal(`\1`, node.Children[2].Pattern)
+	is.Equal(0.0, node.Children[2].Entropy)
+}
+
+func TestExplainEntropyAlterFunction(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`(abc|\d{2})$hex([a-z]{2})\w`))
+	is.NotErr(err)
+	node, err := p.ExplainEntropy()
+	is.NotErr(err)
+	is.Equal(p.Entropy(), node.Entropy)
+	is.Equal(3, len(node.Children))
+
+	alter := node.Children[0]
+	is.Equal(passgen.EntropyKindAlteration, alter.Kind)
+	is.Equal(`(abc|\d{2})`, alter.Pattern)
+	is.Equal(2, alter.Size)
+	is.Equal(2, len(alter.Children))
+	is.Equal(1.0, alter.Entropy)
+
+	function := node.Children[1]
+	is.Equal(passgen.EntropyKindFunction, function.Kind)
+	is.Equal(`$hex([a-z]{2})`, function.Pattern)
+	isFloatBetween(is, function.Entropy, 9.4, 9.41)
+
+	is.Equal(passgen.EntropyKindCharClass, node.Children[2].Kind)
+	is.Equal(`\w`, node.Children[2].Pattern)
+
+	text := node.String()
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	is.Equal(`sequence "(abc|\\d{2})$hex([a-z]{2})\\w": 16.38 bits`, lines[0])
+	is.True(strings.HasPrefix(lines[1], `  alteration "(abc|\\d{2})", log2(2) + minimum`))
+
+	jsonBytes, err := json.Marshal(node)
+	is.NotErr(err)
+	node2 := &passgen.EntropyNode{}
+	is.NotErr(json.Unmarshal(jsonBytes, node2))
+	is.Equal(node.String(), node2.String())
+}
+
+func TestExplainEntropyRepeatLiteral(t *testing.T) {
+	is := is.New(t)
+	test := func(pattern string, repeatPattern string) {
+		is := is.AddMsg("pattern=%#v", pattern)
+		p, err := passgen.Compile([]rune(pattern))
+		is.NotErr(err)
+		node, err := p.ExplainEntropy()
+		is.NotErr(err)
+		is.Equal(1, len(node.Children))
+		repeat := node.Children[0]
+		is.Equal(passgen.EntropyKindRepeat, repeat.Kind)
+		is.Equal(repeatPattern, repeat.Pattern)
+		is.Equal(1, len(repeat.Children))
+		is.Equal(passgen.EntropyKindLiteral, repeat.Children[0].Kind)
+		is.Equal("x", repeat.Children[0].Pattern)
+	}
+	test(`x{3}`, `x{3}`)
+	test(`x?`, `x?`)
+}
@@ -52,8 +52,9 @@ func NewDateGenerator(sep string, startJd int, endJd int) *dateGenerator {
 
 func NewRepeatGenerator(child GeneratorIface, count int64) *repeatGenerator {
 	return &repeatGenerator{
-		child: child,
-		count: count,
+		child:    child,
+		minCount: count,
+		maxCount: count,
 	}
 }
 
@@ -66,3 +67,19 @@ func NewShuffleGenerator(argPattern []rune) *shuffleGenerator {
 		argPattern: argPattern,
 	}
 }
+
+func WordList(name string) []string {
+	return wordLists[name]()
+}
+
+func UnregisterFunction(name string) {
+	unregisterFunction(name)
+}
+
+func UnregisterCharClass(name string) {
+	unregisterCharClass(name)
+}
+
+func UnregisterPattern(name string) {
+	unregisterPattern(name)
+}
@@ -1,19 +1,80 @@
 package passgen
 
+// functionArg is the compiled argument of a function call, that is
+// generated and then passed to a text function like encoders
+type functionArg struct {
+	root *RootGenerator
+	// errPos is the position used for errors of text function
+	errPos errorPos
+}
+
+func compileFunctionArg(s *State, argPattern []rune) (*functionArg, error) {
+	root, err := subCompile(s, argPattern)
+	if err != nil {
+		return nil, err
+	}
+	return &functionArg{
+		root:   root,
+		errPos: s.errorPos(),
+	}, nil
+}
+
 func baseFunctionCallGenerator(
 	s *State,
-	argState *State,
+	arg *functionArg,
 	funcObj func(s *State, in []rune) ([]rune, error),
 ) error {
-	g := NewRootGenerator()
-	err := g.Generate(argState)
+	argState := NewState(s.SharedState, nil)
+	err := arg.root.Generate(argState)
 	if err != nil {
 		return err
 	}
-	result, err := funcObj(s, argState.output)
+	result, err := funcObj(s.withErrorPos(arg.errPos), argState.output)
 	if err != nil {
 		return err
 	}
-	s.addOutputNonRepeatable(result)
+	s.addOutput(result)
 	return nil
 }
+
+// functionCallGenerator wraps the generator of a function call $name(arg)
+type functionCallGenerator struct {
+	gen      GeneratorIface
+	funcName string
+	arg      []rune
+}
+
+func (g *functionCallGenerator) Generate(s *State) error {
+	return g.gen.Generate(s)
+}
+
+func (g *functionCallGenerator) Entropy(s *State) (float64, error) {
+	return g.gen.Entropy(s)
+}
+
+func (g *functionCallGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	node, err := explainGenerator(s, g.gen)
+	if err != nil {
+		return nil, err
+	}
+	node.Kind = EntropyKindFunction
+	node.Pattern = "$" + g.funcName + "(" + string(g.arg) + ")"
+	return node, nil
+}
+
+// explainArgEntropy explains entropy of function that is equal to the
+// entropy of its argument
+func explainArgEntropy(s *State, root *RootGenerator) (*EntropyNode, error) {
+	if root == nil {
+		return nil, s.errorUnknown(s_entropy_not_calc)
+	}
+	argNode, err := root.explainEntropy(s)
+	if err != nil {
+		return nil, err
+	}
+	return &EntropyNode{
+		Detail:   "entropy of argument",
+		Entropy:  argNode.Entropy,
+		Children: []*EntropyNode{argNode},
+	}, nil
+}
@@ -158,65 +158,133 @@ var encoderFunctions = map[string]func(s *State, in []rune) ([]rune, error){
 	},
 }
 
+// generatorFunctions is the map of built-in functions that create their own generator
+var generatorFunctions = map[string]func(s *State, arg []rune) (GeneratorIface, error){
+	"byte": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newByteGenerator(s, arg, false)
+	},
+	"BYTE": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newByteGenerator(s, arg, true)
+	},
+	"bip39word": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newBIP39WordGenerator(s, string(arg))
+	},
+	"shuffle": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newShuffleGenerator(arg)
+	},
+	"date": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newDateGenerator(s, arg)
+	},
+	"?": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newOnceOrNoneGenerator(arg)
+	},
+	"rjust": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newRjustGenerator(s, arg)
+	},
+	"ljust": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newLjustGenerator(s, arg)
+	},
+	"center": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newCenterGenerator(s, arg)
+	},
+	"use": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newUseGenerator(s, arg)
+	},
+	"require": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newRequireGenerator(s, arg)
+	},
+	"distinct": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newDistinctGenerator(arg)
+	},
+	"noadjacent": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newNoAdjacentGenerator(arg)
+	},
+	"weighted": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newWeightedGenerator(arg)
+	},
+	"word": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newWordGenerator(s, arg)
+	},
+	"wordfile": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newWordFileGenerator(s, arg)
+	},
+	"line": func(s *State, arg []rune) (GeneratorIface, error) {
+		return newLineGenerator(s, arg)
+	},
+}
+
 type encoderFunctionCallGenerator struct {
-	entropy    *float64
+	arg        *functionArg
 	funcName   string
 	argPattern []rune
+	encoder    func(s *State, in []rune) ([]rune, error)
 }
 
-func (g *encoderFunctionCallGenerator) Generate(s *State) error {
+func (g *encoderFunctionCallGenerator) compile(s *State) error {
 	funcName := g.funcName
-	funcObj, ok := encoderFunctions[funcName]
-	if !ok {
+	encoder := getEncoderFunction(funcName)
+	if encoder == nil {
 		s.errorMarkLen = len(funcName) + 2
 		return s.errorValue("invalid function '%v'", funcName)
 	}
-	err := baseFunctionCallGenerator(
-		s,
-		NewState(s.SharedState, g.argPattern),
-		funcObj,
-	)
+	arg, err := compileFunctionArg(s, g.argPattern)
 	if err != nil {
 		return err
 	}
-	g.entropy = &s.patternEntropy
+	g.arg = arg
+	g.encoder = encoder
 	return nil
 }
 
+func (g *encoderFunctionCallGenerator) Generate(s *State) error {
+	if g.arg == nil {
+		err := g.compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	return baseFunctionCallGenerator(
+		s,
+		g.arg,
+		g.encoder,
+	)
+}
+
 func (g *encoderFunctionCallGenerator) Entropy(s *State) (float64, error) {
-	if g.entropy != nil {
-		return *g.entropy, nil
+	if g.arg == nil {
+		return 0, s.errorUnknown(s_entropy_not_calc)
 	}
-	return 0, s.errorUnknown(s_entropy_not_calc)
+	return g.arg.root.Entropy(s)
 }
 
 func getFuncGenerator(s *State, funcName string, arg []rune) (GeneratorIface, error) {
-	if _, ok := encoderFunctions[funcName]; ok {
+	if getEncoderFunction(funcName) != nil {
 		return &encoderFunctionCallGenerator{
 			funcName:   funcName,
 			argPattern: arg,
 		}, nil
 	}
-	switch funcName {
-	case "byte":
-		return newByteGenerator(s, arg, false)
-	case "BYTE":
-		return newByteGenerator(s, arg, true)
-	case "bip39word":
-		return newBIP39WordGenerator(s, string(arg))
-	case "shuffle":
-		return newShuffleGenerator(arg)
-	case "date":
-		return newDateGenerator(s, arg)
-	case "?":
-		return newOnceOrNoneGenerator(arg)
-	case "rjust":
-		return newRjustGenerator(s, arg)
-	case "ljust":
-		return newLjustGenerator(s, arg)
-	case "center":
-		return newCenterGenerator(s, arg)
+	if newGen, ok := generatorFunctions[funcName]; ok {
+		return newGen(s, arg)
+	}
+	if spec := getCustomFunction(funcName); spec != nil && spec.Generator != nil {
+		gen, err := spec.Generator(arg)
+		if err != nil {
+			if len(arg) > 0 {
+				s.errorOffset += int64(len(arg))
+				s.errorMarkLen = len(arg)
+			}
+			return nil, customFunctionError(s, err)
+		}
+		return gen, nil
 	}
 	s.errorMarkLen = len(funcName) + 2
 	return nil, s.errorValue("invalid function '%v'", funcName)
 }
+
+func (g *encoderFunctionCallGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	if g.arg == nil {
+		return nil, s.errorUnknown(s_entropy_not_calc)
+	}
+	return explainArgEntropy(s, g.arg.root)
+}
@@ -1,7 +1,6 @@
 package passgen_test
 
 import (
-	"os"
 	"testing"
 	"time"
 
@@ -19,14 +18,16 @@ func FuzzGenerate(f *testing.F) {
 	for _, tc := range testcases {
 		f.Add(tc) // Use f.Add to provide a seed corpus
 	}
-	// define a max output length to prevent out-of-memory and crash
-	errPanic(os.Setenv("REPASSGEN_MAX_LENGTH", "500"))
 	f.Fuzz(func(t *testing.T, pattern string) {
 		if len(pattern) > 100 {
 			return
 		}
 		out, _, err := passgen.Generate(passgen.GenerateInput{
 			Pattern: []rune(pattern),
+			// define a max output length to prevent out-of-memory and crash
+			Limits: passgen.Limits{
+				MaxOutputLength: 500,
+			},
 		})
 		if err != nil {
 			return
@@ -1,19 +1,64 @@
 package passgen
 
 import (
-	"fmt"
+	"io"
 	"log"
 )
 
 // GenerateInput is struct given to Generate
 type GenerateInput struct {
 	Pattern []rune
+
+	// Rand is the source of random bytes, crypto/rand.Reader is used if nil
+	Rand io.Reader
+
+	// Limits is the resource limits, see Limits for default values
+	Limits Limits
+
+	// Uniform makes every password matching the pattern equally likely
+	// by weighting counts of range repetition {M,N} and branches of
+	// alteration (A|B) by their keyspace size, instead of choosing them
+	// with equal probability
+	Uniform bool
+
+	// Universe is the set of characters that negated character classes,
+	// like [^abc], are complemented against
+	// printable ASCII characters (32 to 126) are used if nil
+	// see Universe function for Unicode scripts and categories
+	Universe []rune
+
+	// Exclude is the characters that are removed from every character
+	// class of pattern, like [:alnum:], \w or [a-z], and from hex digits
+	// of $byte(), see AmbiguousChars
+	// literal characters of pattern are not removed
+	Exclude []rune
+
+	// CharClasses is the named character classes that can be used in
+	// pattern as [:name:], like RegisterCharClass but only for this pattern
+	// names must not be the name of a built-in or registered class
+	CharClasses map[string][]rune
+
+	// AllowFiles allows $wordfile and $line to read files
+	AllowFiles bool
+
+	// FileDir is the directory that $wordfile and $line can read files
+	// from, if not empty, relative paths are relative to it
+	// files are not restricted to a directory if empty
+	FileDir string
 }
 
+// AmbiguousChars is the characters that are easily confused with each
+// other when printed, to be used as GenerateInput.Exclude
+const AmbiguousChars = "0O1lI|`'\""
+
 // GenerateOutput is struct returned by Generate
 type GenerateOutput struct {
 	Password       []rune
 	PatternEntropy float64
+
+	// Groups is the output of named groups (?<name>...) of pattern
+	// a group that is not generated, like inside $?(...), is not included
+	Groups map[string][]rune
 }
 
 // Generate generates random password based on given pattern
@@ -25,30 +70,29 @@ func Generate(in GenerateInput) (*GenerateOutput, *State, error) {
 			log.Printf("panic in Generate: %v, pattern=`%v`", r, in.Pattern)
 		}
 	}()
-	if len(in.Pattern) > 1000 {
-		return nil, nil, fmt.Errorf("pattern is too long")
-	}
-	s := NewState(NewSharedState(), in.Pattern)
-	g := NewRootGenerator()
-
-	err := g.Generate(s)
+	p, s, err := compileInput(in)
 	if err != nil {
 		return nil, s, err
 	}
-
-	return &GenerateOutput{
-		Password:       s.output,
-		PatternEntropy: s.patternEntropy,
-	}, s, nil
+	return p.generate()
 }
 
-func subGenerate(s *State, pattern []rune) ([]rune, error) {
+// subCompile compiles a sub-pattern (of group, function argument etc)
+// using the shared state of s
+func subCompile(s *State, pattern []rune) (*RootGenerator, error) {
+	if s.maxDepth > 0 && s.depth >= s.maxDepth {
+		return nil, s.errorValue("nesting depth is more than %d", s.maxDepth)
+	}
+	s.depth++
+	defer func() {
+		s.depth--
+	}()
 	childGen := NewRootGenerator()
 	s2 := NewState(s.SharedState, pattern)
-	err := childGen.Generate(s2)
+	err := childGen.compile(s2)
 	if err != nil {
 		return nil, err
 	}
 	s.lastGen = nil
-	return s2.output, nil
+	return childGen, nil
 }
@@ -0,0 +1,84 @@
+package passgen
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// GenerateMany generates count random passwords based on given pattern
+// the pattern is compiled only once, and passwords are generated in parallel
+// goroutines, unless in.Rand is set, in which case they are generated
+// sequentially so that the output is reproducible with a seeded source
+func GenerateMany(in GenerateInput, count int) ([]*GenerateOutput, error) {
+	p, _, err := compileInput(in)
+	if err != nil {
+		return nil, err
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if in.Rand != nil {
+		workers = 1
+	}
+	return p.GenerateMany(count, workers)
+}
+
+// GenerateMany generates count random passwords using given number of
+// goroutines, the random source of pattern (if set) must be safe for
+// concurrent use if workers > 1
+func (p *Pattern) GenerateMany(count int, workers int) ([]*GenerateOutput, error) {
+	if count < 0 {
+		return nil, fmt.Errorf("invalid count %v", count)
+	}
+	results := make([]*GenerateOutput, count)
+	if workers < 2 || count < 2 {
+		for i := range results {
+			out, err := p.Generate()
+			if err != nil {
+				return nil, err
+			}
+			results[i] = out
+		}
+		return results, nil
+	}
+	workers = min(workers, count)
+
+	indexCh := make(chan int)
+	done := make(chan struct{})
+	var firstErr error
+	var errOnce sync.Once
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexCh {
+				out, err := p.Generate()
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						close(done)
+					})
+					return
+				}
+				results[i] = out
+			}
+		}()
+	}
+
+Loop:
+	for i := range count {
+		select {
+		case indexCh <- i:
+		case <-done:
+			break Loop
+		}
+	}
+	close(indexCh)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
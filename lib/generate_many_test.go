@@ -0,0 +1,66 @@
+package passgen_test
+
+import (
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestGenerateMany(t *testing.T) {
+	is := is.New(t)
+	outList, err := passgen.GenerateMany(passgen.GenerateInput{
+		Pattern: []rune(`[:alnum:]{16}`),
+	}, 100)
+	is.NotErr(err)
+	is.Equal(100, len(outList))
+	passwords := map[string]bool{}
+	for _, out := range outList {
+		is.Equal(16, len(out.Password))
+		isFloatBetween(is, out.PatternEntropy, 95.2, 95.3)
+		passwords[string(out.Password)] = true
+	}
+	is.Equal(100, len(passwords))
+}
+
+func TestGenerateManyRand(t *testing.T) {
+	is := is.New(t)
+	gen := func() []string {
+		outList, err := passgen.GenerateMany(passgen.GenerateInput{
+			Pattern: []rune(allRandomPattern),
+			Rand:    seededRand(1),
+		}, 10)
+		is.NotErr(err)
+		passwords := make([]string, len(outList))
+		for i, out := range outList {
+			passwords[i] = string(out.Password)
+		}
+		return passwords
+	}
+	is.Equal(gen(), gen())
+}
+
+func TestGenerateManyError(t *testing.T) {
+	is := is.New(t)
+	{
+		outList, err := passgen.GenerateMany(passgen.GenerateInput{
+			Pattern: []rune(`[:foo:]`),
+		}, 10)
+		is.Nil(outList)
+		is.ErrMsg(err, `value error near index 5: invalid character class "foo"`)
+	}
+	{
+		outList, err := passgen.GenerateMany(passgen.GenerateInput{
+			Pattern: []rune(`$base64([g])`),
+		}, 10)
+		is.Nil(outList)
+		is.ErrMsg(err, `value error near index 10: invalid hex number "g"`)
+	}
+	{
+		p, err := passgen.Compile([]rune(`abc`))
+		is.NotErr(err)
+		outList, err := p.GenerateMany(-1, 2)
+		is.Nil(outList)
+		is.ErrMsg(err, `invalid count -1`)
+	}
+}
@@ -1,5 +1,7 @@
 package passgen
 
+import "strconv"
+
 func newGroupGenerator(pattern []rune) *groupGenerator {
 	return &groupGenerator{
 		pattern: pattern,
@@ -7,23 +9,86 @@ func newGroupGenerator(pattern []rune) *groupGenerator {
 }
 
 type groupGenerator struct {
-	entropy *float64
+	root    *RootGenerator
 	pattern []rune
+	// groupId is zero for non-capturing group (?:...)
+	groupId uint64
+	// source is the pattern of group including parentheses
+	source []rune
+}
+
+func (g *groupGenerator) compile(s *State) error {
+	root, err := subCompile(s, g.pattern)
+	if err != nil {
+		return err
+	}
+	g.root = root
+	return nil
 }
 
 func (g *groupGenerator) Generate(s *State) error {
-	output, err := subGenerate(s, g.pattern)
+	if g.root == nil {
+		err := g.compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	start := len(s.output)
+	err := g.root.Generate(s)
 	if err != nil {
 		return err
 	}
-	s.output = append(s.output, output...)
-	g.entropy = &s.patternEntropy
+	if g.groupId > 0 {
+		s.groupsOutput[g.groupId] = s.output[start:]
+	}
 	return nil
 }
 
 func (g *groupGenerator) Entropy(s *State) (float64, error) {
-	if g.entropy != nil {
-		return *g.entropy, nil
+	if g.root == nil {
+		return 0, s.errorUnknown(s_entropy_not_calc)
+	}
+	return g.root.Entropy(s)
+}
+
+// groupRefGenerator generates the output of a previous group, like \1 or \k<name>
+type groupRefGenerator struct {
+	groupId uint64
+	name    string
+}
+
+func (g *groupRefGenerator) Generate(s *State) error {
+	s.addOutput(s.groupsOutput[g.groupId])
+	return nil
+}
+
+func (g *groupRefGenerator) Entropy(_ *State) (float64, error) {
+	return 0, nil
+}
+
+func (g *groupGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	if g.root == nil {
+		return nil, s.errorUnknown(s_entropy_not_calc)
+	}
+	node, err := g.root.explainEntropy(s)
+	if err != nil {
+		return nil, err
+	}
+	node.Kind = EntropyKindGroup
+	node.Pattern = "(" + node.Pattern + ")"
+	if g.source != nil {
+		node.Pattern = string(g.source)
+	}
+	return node, nil
+}
+
+func (g *groupRefGenerator) explainEntropy(_ *State) (*EntropyNode, error) {
+	pattern := "\\" + strconv.FormatUint(g.groupId, 10)
+	if g.name != "" {
+		pattern = "\\k<" + g.name + ">"
 	}
-	return 0, s.errorUnknown(s_entropy_not_calc)
+	return &EntropyNode{
+		Kind:    EntropyKindGroupRef,
+		Pattern: pattern,
+	}, nil
 }
@@ -27,3 +27,132 @@ func TestGroupGenerator(t *testing.T) {
 		isFloatBetween(is, entropy, 59.6, 59.7)
 	}
 }
+
+func TestNamedGroup(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(
+		`(?<user>[a-z]{4})(x|y)(?<year>20[0-9]{2}|19[0-9]{2})-\k<user>-\k<year>-\2`,
+	))
+	is.NotErr(err)
+	isFloatBetween(is, p.Entropy(), 27.44, 27.45)
+	for range 10 {
+		out, err := p.Generate()
+		is.NotErr(err)
+		pw := string(out.Password)
+		is.Equal(21, len(pw))
+		user := string(out.Groups["user"])
+		year := string(out.Groups["year"])
+		is.Equal(4, len(user))
+		is.Equal(4, len(year))
+		is.Equal(2, len(out.Groups))
+		is.Equal(user+pw[4:5]+year+"-"+user+"-"+year+"-"+pw[4:5], pw)
+	}
+	{
+		node, err := p.ExplainEntropy()
+		is.NotErr(err)
+		is.Equal(`(?<year>20[0-9]{2}|19[0-9]{2})`, node.Children[2].Pattern)
+		is.Equal(`\k<user>`, node.Children[4].Pattern)
+	}
+	{
+		p, err := passgen.Compile([]rune(`[a-z]{4}`))
+		is.NotErr(err)
+		out, err := p.Generate()
+		is.NotErr(err)
+		is.Nil(out.Groups)
+	}
+}
+
+func TestNamedGroupInAlteration(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`((?<x>a)|(?<y>b))\k<x>\k<y>-(c|(?<z>d))\5`))
+	is.NotErr(err)
+	is.Equal(2.0, p.Entropy())
+	for range 20 {
+		out, err := p.Generate()
+		is.NotErr(err)
+		pw := string(out.Password)
+		is := is.AddMsg("password=%#v", pw)
+		switch pw[:3] {
+		case "aa-":
+			is.Equal("a", string(out.Groups["x"]))
+		case "bb-":
+			is.Equal("b", string(out.Groups["y"]))
+		default:
+			t.Fatalf("unexpected password %#v", pw)
+		}
+		switch pw[3:] {
+		case "c":
+			is.Equal(1, len(out.Groups))
+		case "dd":
+			is.Equal(2, len(out.Groups))
+			is.Equal("d", string(out.Groups["z"]))
+		default:
+			t.Fatalf("unexpected password %#v", pw)
+		}
+	}
+}
+
+func TestNonCapturingGroup(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`(?:[a-z]{2}-){3}(?:x|y)([0-9]{3})\1`))
+	is.NotErr(err)
+	isFloatBetween(is, p.Entropy(), 39.16, 39.17)
+	for range 10 {
+		out, err := p.Generate()
+		is.NotErr(err)
+		pw := string(out.Password)
+		is.Equal(16, len(pw))
+		is.Equal(pw[10:13], pw[13:])
+	}
+	node, err := p.ExplainEntropy()
+	is.NotErr(err)
+	is.Equal(`(?:[a-z]{2}-){3}`, node.Children[0].Pattern)
+	is.Equal(`(?:x|y)`, node.Children[1].Pattern)
+	is.Equal(`([0-9]{3})`, node.Children[2].Pattern)
+}
+
+func TestNonCapturingGroupInAlteration(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`((?:ab){2}|c)(?:(?:x|y)z|w)(q)\1\2`))
+	is.NotErr(err)
+	// log2(2) + log2(2) + minimum of log2(2) and 0
+	is.Equal(2.0, p.Entropy())
+	expected := map[string]bool{}
+	for _, first := range []string{"abab", "c"} {
+		for _, second := range []string{"xz", "yz", "w"} {
+			expected[first+second+"q"+first+"q"] = true
+		}
+	}
+	for range 20 {
+		out, err := p.Generate()
+		is.NotErr(err)
+		is.AddMsg("password=%#v", string(out.Password)).True(expected[string(out.Password)])
+	}
+}
+
+func TestPlainGroupInAlteration(t *testing.T) {
+	// other parentheses in branches are literal characters, like before
+	// (?:...) and (?<name>...) were supported in branches
+	testGen(t, &genCase{
+		Pattern:  `(()|())`,
+		PassLen:  [2]int{2, 2},
+		Entropy:  [2]float64{1, 1},
+		Password: strPtr(`()`),
+	})
+	testGen(t, &genCase{
+		Pattern: `(a|(b)c)`,
+		PassLen: [2]int{1, 4},
+		Entropy: [2]float64{1, 1},
+		Validate: func(p string) bool {
+			return p == "a" || p == "(b)c"
+		},
+	})
+	testGen(t, &genCase{
+		Pattern: `(a|(?:b|(cd)))`,
+		PassLen: [2]int{1, 4},
+		Entropy: [2]float64{1, 2},
+		Validate: func(p string) bool {
+			return p == "a" || p == "b" || p == "(cd)"
+		},
+	})
+}
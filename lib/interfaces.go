@@ -4,3 +4,9 @@ type GeneratorIface interface {
 	Generate(s *State) error
 	Entropy(s *State) (float64, error)
 }
+
+// compilerIface is implemented by generators that have a sub-pattern
+// which needs to be compiled after the generator is created
+type compilerIface interface {
+	compile(s *State) error
+}
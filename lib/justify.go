@@ -53,27 +53,41 @@ type JustifyArgs struct {
 }
 
 type justifyGenerator struct {
-	entropy     *float64
+	root        *RootGenerator
 	args        *JustifyArgs
 	justifyFunc func([]rune, int, rune) []rune
 }
 
+func (g *justifyGenerator) compile(s *State) error {
+	root, err := subCompile(s, g.args.pattern)
+	if err != nil {
+		return err
+	}
+	g.root = root
+	return nil
+}
+
 func (g *justifyGenerator) Generate(s *State) error {
-	output, err := subGenerate(s, g.args.pattern)
+	if g.root == nil {
+		err := g.compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	s2 := NewState(s.SharedState, nil)
+	err := g.root.Generate(s2)
 	if err != nil {
 		return err
 	}
-	output = g.justifyFunc(output, g.args.width, g.args.fillChar)
-	s.output = append(s.output, output...)
-	g.entropy = &s.patternEntropy
+	s.addOutput(g.justifyFunc(s2.output, g.args.width, g.args.fillChar))
 	return nil
 }
 
 func (g *justifyGenerator) Entropy(s *State) (float64, error) {
-	if g.entropy != nil {
-		return *g.entropy, nil
+	if g.root == nil {
+		return 0, s.errorUnknown(s_entropy_not_calc)
 	}
-	return 0, s.errorUnknown(s_entropy_not_calc)
+	return g.root.Entropy(s)
 }
 
 func parseJustifyArgs(s *State, argsStr []rune, funcName string) (*JustifyArgs, error) {
@@ -149,3 +163,7 @@ func newCenterGenerator(s *State, argsStr []rune) (*justifyGenerator, error) {
 		justifyFunc: center,
 	}, nil
 }
+
+func (g *justifyGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	return explainArgEntropy(s, g.root)
+}
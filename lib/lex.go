@@ -36,23 +36,33 @@ func LexRoot(s *State) (LexType, error) {
 			return nil, s.errorSyntax(err_nestedBracket)
 		}
 		s.openBracket = true
+		s.rangeStart = s.inputPos - 1
 		return lexRange, nil
 	case '{':
 		if s.lastGen == nil {
 			return nil, s.errorSyntax("nothing to repeat")
 		}
 		return lexRepeat, nil
+	case '?':
+		if s.lastGen == nil {
+			return nil, s.errorSyntax("nothing to repeat")
+		}
+		// X? is the same as X{0,1}
+		s.replaceLastGen(&repeatGenerator{
+			child:    s.lastGen,
+			minCount: 0,
+			maxCount: 1,
+		})
+		return LexRoot, nil
 	case '(':
 		s.openParenth++
 		s.lastGroupId++
-		return lexGroup, nil
+		s.groupStart = s.inputPos - 1
+		return lexGroupStart, nil
 	case '$':
 		return lexIdent, nil
 	}
-	err := s.addOutputOne(c)
-	if err != nil {
-		return nil, err
-	}
+	s.addStatic([]rune{c})
 	return LexRoot, nil
 }
 
@@ -96,11 +106,17 @@ func lexBackslash(s *State) (LexType, error) {
 		return processCharClass(s, []rune(s_digits))
 	case 'w':
 		return processCharClass(s, wordChars)
+	case 'k':
+		return processNamedGroupRef(s, LexRoot)
+	case 'p', 'P':
+		patternStart := s.inputPos - 2
+		chars, err := lexUnicodeProperty(s, c)
+		if err != nil {
+			return nil, err
+		}
+		return addCharClass(s, chars, patternStart)
 	}
-	err := s.addOutputOne(backslashEscape(c))
-	if err != nil {
-		return nil, err
-	}
+	s.addStatic([]rune{backslashEscape(c)})
 	return LexRoot, nil
 }
 
@@ -141,10 +157,7 @@ func makeLexUnicode(parentLex LexType, symbol rune, width int, toBuffer bool) Le
 		if toBuffer {
 			s.buffer = append(s.buffer, char)
 		} else {
-			err := s.addOutputOne(char)
-			if err != nil {
-				return nil, err
-			}
+			s.addStatic([]rune{char})
 		}
 		return parentLex, nil
 	}
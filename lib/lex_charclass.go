@@ -1,22 +1,45 @@
 package passgen
 
+import "strings"
+
 func processCharClass(s *State, chars []rune) (LexType, error) {
+	// \d or \w if not inside [...]
+	patternStart := s.inputPos - 2
+	if s.openBracket {
+		patternStart = s.rangeStart
+	}
+	return addCharClass(s, chars, patternStart)
+}
+
+func addCharClass(s *State, chars []rune, patternStart uint64) (LexType, error) {
 	reverse := s.rangeReverse
 	s.openBracket = false
 	s.rangeReverse = false
 	chars = removeDuplicateRunes(chars)
 	if reverse {
-		chars = excludeCharsASCII(chars)
+		chars = s.complementChars(chars)
+	}
+	pattern := s.input[patternStart:s.inputPos]
+	// empty Unicode property or result of set operation is an error
+	// but an empty class like [] is allowed
+	if len(chars) == 0 && strings.Trim(string(pattern), "[]") != "" {
+		s.errorMarkLen = len(pattern)
+		return nil, s.errorValue("character class is empty")
+	}
+	if len(s.exclude) > 0 && len(chars) > 0 {
+		chars = subtractRunes(chars, s.exclude)
+		if len(chars) == 0 {
+			s.errorMarkLen = len(pattern)
+			return nil, s.errorValue("all characters of class are excluded")
+		}
 	}
 	gen := &charClassGenerator{
 		charClasses: [][]rune{chars},
+		pattern:     pattern,
 	}
-	err := gen.Generate(s)
-	if err != nil {
-		return nil, err
-	}
+	gen.getEntropy()
 	s.buffer = nil
-	s.lastGen = gen
+	s.addGen(gen)
 	return LexRoot, nil
 }
 
@@ -31,17 +54,29 @@ func lexRange(s *State) (LexType, error) {
 	case '\\':
 		return lexRangeBackslash, nil
 	case '[':
-		return nil, s.errorSyntax(err_nestedBracket)
+		s.openNestedCharClass(0)
+		return lexRange, nil
 	case ':':
 		return lexRangeColon, nil
 	case '-':
+		if s.nextIsSetOperation('-') {
+			return lexRangeSetOperation, nil
+		}
 		return lexRangeDashInit, nil
+	case '&':
+		if s.nextIsSetOperation('&') {
+			return lexRangeSetOperation, nil
+		}
 	case '^':
-		if !s.rangeReverse && len(s.buffer) == 0 {
+		if !s.rangeReverse && s.inputPos == s.rangeStart+2 {
 			s.rangeReverse = true
 			return lexRange, nil
 		}
 	case ']':
+		if len(s.classStack) > 0 {
+			s.closeNestedCharClass()
+			return lexRange, nil
+		}
 		return processCharClass(s, s.buffer)
 	}
 	s.buffer = append(s.buffer, c)
@@ -60,7 +95,11 @@ func lexRangeColon(s *State) (LexType, error) {
 		switch c {
 		case ':':
 			name := string(nameRunes)
-			chars, ok := charClasses[name]
+			chars, ok := s.getCharClass(name)
+			if !ok {
+				// Unicode script like [:cyrillic:] or [:script=Han:]
+				chars, ok = getUnicodeClass(name, true)
+			}
 			if !ok {
 				s.errorMarkLen = len(name) + 2
 				return nil, s.errorValue("invalid character class %#v", name)
@@ -126,6 +165,14 @@ func lexRangeBackslash(s *State) (LexType, error) {
 	if c == 'U' {
 		return makeLexUnicode(lexRange, 'U', 10, true), nil
 	}
+	if c == 'p' || c == 'P' {
+		chars, err := lexUnicodeProperty(s, c)
+		if err != nil {
+			return nil, err
+		}
+		s.buffer = append(s.buffer, chars...)
+		return lexRange, nil
+	}
 	s.buffer = append(s.buffer, backslashEscape(c))
 	return lexRange, nil
 }
@@ -145,3 +192,79 @@ func lexRangeDashBackslash(s *State) (LexType, error) {
 	s.buffer = append(s.buffer, backslashEscape(c))
 	return lexRangeDash, nil
 }
+
+// classFrame is the saved state of an outer character class, while
+// parsing a nested [...] inside it
+type classFrame struct {
+	buffer     []rune
+	reverse    bool
+	rangeStart uint64
+	// op is the set operation ('-' for "--" or '&' for "&&") to apply
+	// on buffer and result of nested class, or 0 for union
+	op rune
+}
+
+// nextIsSetOperation returns true if "--[" or "&&[" is at current position
+// (after moving past the first op character)
+func (s *State) nextIsSetOperation(op rune) bool {
+	pos := s.inputPos
+	if pos+1 >= uint64(len(s.input)) {
+		return false
+	}
+	return s.input[pos] == op && s.input[pos+1] == '['
+}
+
+func (s *State) openNestedCharClass(op rune) {
+	s.classStack = append(s.classStack, classFrame{
+		buffer:     s.buffer,
+		reverse:    s.rangeReverse,
+		rangeStart: s.rangeStart,
+		op:         op,
+	})
+	s.buffer = nil
+	s.rangeReverse = false
+	s.rangeStart = s.inputPos - 1
+}
+
+func (s *State) closeNestedCharClass() {
+	chars := removeDuplicateRunes(s.buffer)
+	if s.rangeReverse {
+		chars = s.complementChars(chars)
+	}
+	n := len(s.classStack)
+	frame := s.classStack[n-1]
+	s.classStack = s.classStack[:n-1]
+	s.rangeReverse = frame.reverse
+	s.rangeStart = frame.rangeStart
+	switch frame.op {
+	case '-':
+		s.buffer = subtractRunes(frame.buffer, chars)
+	case '&':
+		s.buffer = intersectRunes(frame.buffer, chars)
+	default:
+		s.buffer = append(frame.buffer, chars...)
+	}
+}
+
+// lexRangeSetOperation is called after first character of "--[" or "&&["
+// the left operand is everything before the operator in current class
+func lexRangeSetOperation(s *State) (LexType, error) {
+	op := s.input[s.inputPos-1]
+	if len(s.buffer) == 0 {
+		s.errorOffset++
+		s.errorMarkLen = 2
+		return nil, s.errorSyntax("no character before '%c%c'", op, op)
+	}
+	s.move(2)
+	s.openNestedCharClass(op)
+	return lexRange, nil
+}
+
+// complementChars returns the characters of negation universe that are not
+// in exclude, universe is printable ASCII characters by default
+func (s *State) complementChars(exclude []rune) []rune {
+	if s.universe == nil {
+		return excludeCharsASCII(exclude)
+	}
+	return subtractRunes(s.universe, exclude)
+}
@@ -19,15 +19,19 @@ func _lexIdentFuncCallParanClose(s *State, buffer []rune) (LexType, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = gen.Generate(s2)
-	if err != nil {
-		return nil, err
+	if cgen, ok := gen.(compilerIface); ok {
+		err = cgen.compile(s2)
+		if err != nil {
+			return nil, err
+		}
 	}
-	s.output = append(s.output, s2.output...)
-	s.patternEntropy = s2.patternEntropy
 	s.lastGroupId = s2.lastGroupId
 	s.buffer = nil
-	s.lastGen = gen
+	s.addGen(&functionCallGenerator{
+		gen:      gen,
+		funcName: funcName,
+		arg:      buffer,
+	})
 	return LexRoot, nil
 }
 
@@ -53,6 +57,8 @@ func lexIdentFuncCall(s *State) (LexType, error) {
 		return nil, _lexIdentFuncCallEndError(s)
 	}
 	buffer := []rune{}
+	// nestedBrackets is the number of open nested [...] inside character class
+	nestedBrackets := 0
 	for ; !s.end(); s.move(1) {
 		c := s.input[s.inputPos]
 		switch c {
@@ -66,10 +72,15 @@ func lexIdentFuncCall(s *State) (LexType, error) {
 			s.openParenth++
 		case '[':
 			if s.openBracket {
-				return nil, s.errorSyntax(err_nestedBracket)
+				nestedBrackets++
+				break
 			}
 			s.openBracket = true
 		case ']':
+			if nestedBrackets > 0 {
+				nestedBrackets--
+				break
+			}
 			s.openBracket = false
 		case ')':
 			lex, err := _lexIdentFuncCallParanClose(s, buffer)
@@ -24,6 +24,10 @@ func lexGroup(s *State) (LexType, error) {
 		}
 		return processGroupEnd(s)
 	case '|':
+		if s.openParenth > 1 && innermostGroupIsNested(s.buffer) {
+			// alteration of a nested (?:...) or (?<name>...) group
+			break
+		}
 		if s.end() {
 			s.errorOffset++
 			return nil, s.errorSyntax("'|' at the end of group")
@@ -35,10 +39,82 @@ func lexGroup(s *State) (LexType, error) {
 	return lexGroup, nil
 }
 
+// lexGroupStart parses the name of named group (?<name>...) if there is any
+// or the prefix of non-capturing group (?:...)
+func lexGroupStart(s *State) (LexType, error) {
+	s.groupNoCapture = false
+	if s.inputPos+1 >= uint64(len(s.input)) || s.input[s.inputPos] != '?' {
+		return lexGroup, nil
+	}
+	switch s.input[s.inputPos+1] {
+	case ':':
+		s.move(2)
+		s.groupNoCapture = true
+		// non-capturing group does not take a group id
+		s.lastGroupId--
+		return lexGroup, nil
+	case '<':
+	default:
+		return lexGroup, nil
+	}
+	s.move(2)
+	name, err := lexGroupName(s)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := s.groupNames[name]; ok {
+		s.errorMarkLen = len(name) + 2
+		return nil, s.errorValue("duplicate group name '%v'", name)
+	}
+	s.groupNames[name] = s.lastGroupId
+	return lexGroup, nil
+}
+
+// lexGroupName parses "name>" of (?<name> or \k<name>, and checks
+// that the name consists of letters, digits and underscore, and does not
+// start with a digit
+func lexGroupName(s *State) (string, error) {
+	nameRunes := []rune{}
+	for ; !s.end(); s.move(1) {
+		c := s.input[s.inputPos]
+		if c == '>' {
+			s.move(1)
+			name := string(nameRunes)
+			if !isValidGroupName(nameRunes) {
+				s.errorMarkLen = len(nameRunes) + 2
+				return "", s.errorValue("invalid group name '%v'", name)
+			}
+			return name, nil
+		}
+		nameRunes = append(nameRunes, c)
+	}
+	s.errorOffset++
+	return "", s.errorSyntax("'<' not closed")
+}
+
+func isValidGroupName(name []rune) bool {
+	if len(name) == 0 || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func lexGroupAlter(s *State) (LexType, error) {
 	pattern := []rune{}
 	length := uint64(0)
 	openParenth := 1
+	// nested is true for every open (?:...) or (?<name>...) group, which
+	// is compiled with the branch, other parentheses inside branches are
+	// literal characters
+	nested := []bool{}
+	nestedCount := 0
 Loop:
 	for ; !s.end(); s.move(1) {
 		length++
@@ -51,13 +127,36 @@ Loop:
 				pattern = append(pattern, '\\')
 				break
 			}
+			if nestedCount > 0 {
+				length++
+				pattern = append(pattern, '\\', s.input[s.inputPos])
+				break
+			}
 			pattern = append(pattern, '\\', c)
 		case '(':
 			openParenth++
+			isNested := s.isGroupPrefix(s.inputPos + 1)
+			nested = append(nested, isNested)
+			if isNested {
+				nestedCount++
+			}
+			if nestedCount > 0 {
+				pattern = append(pattern, c)
+				break
+			}
 			pattern = append(pattern, '\\', c)
 		case ')':
 			openParenth--
 			if openParenth > 0 {
+				inNested := nestedCount > 0
+				if nested[len(nested)-1] {
+					nestedCount--
+				}
+				nested = nested[:len(nested)-1]
+				if inNested {
+					pattern = append(pattern, c)
+					break
+				}
 				pattern = append(pattern, '\\', c)
 				break
 			}
@@ -70,22 +169,63 @@ Loop:
 	if err != nil {
 		return nil, err
 	}
+	groupId := s.lastGroupId
+	if s.groupNoCapture {
+		groupId = 0
+	}
 	gen := &alterGenerator{
 		parts:     parts,
 		indexList: indexList,
 		length:    length,
+		groupId:   groupId,
+		pattern:   s.input[s.groupStart:min(s.inputPos+1, uint64(len(s.input)))],
 	}
-	err = gen.Generate(s)
+	err = gen.compile(s)
 	if err != nil {
 		return nil, err
 	}
 	s.move(1)
 	s.openParenth--
-	s.lastGen = gen
+	if groupId > 0 {
+		s.definedGroups[groupId] = true
+	}
+	s.addGen(gen)
 	s.buffer = nil
 	return LexRoot, nil
 }
 
+// isGroupPrefix returns true if "?:" or "?<" of a non-capturing or named
+// group is at given position of input
+func (s *State) isGroupPrefix(pos uint64) bool {
+	if pos+1 >= uint64(len(s.input)) || s.input[pos] != '?' {
+		return false
+	}
+	return s.input[pos+1] == ':' || s.input[pos+1] == '<'
+}
+
+// innermostGroupIsNested returns true if the innermost open group in
+// buffer of group is a non-capturing or named group
+func innermostGroupIsNested(buffer []rune) bool {
+	depth := 0
+	for i := len(buffer) - 1; i >= 0; i-- {
+		if i > 0 && buffer[i-1] == '\\' {
+			continue
+		}
+		switch buffer[i] {
+		case ')':
+			depth++
+		case '(':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			rest := buffer[i+1:]
+			return len(rest) > 1 && rest[0] == '?' && (rest[1] == ':' || rest[1] == '<')
+		}
+	}
+	return false
+}
+
 func processGroupBackslash(s *State) (LexType, error) {
 	if s.end() {
 		s.errorOffset++
@@ -99,20 +239,23 @@ func processGroupBackslash(s *State) (LexType, error) {
 
 func processGroupEnd(s *State) (LexType, error) {
 	groupId := s.lastGroupId
-	lastOutputSize := len(s.output)
+	if s.groupNoCapture {
+		groupId = 0
+	}
 	s2 := NewState(s.SharedState.Copy(), s.input)
-	s2.output = s.output
 	s2.errorOffset -= int64(len(s.buffer) + 1)
 	gen := newGroupGenerator(s.buffer)
-	err := gen.Generate(s2)
+	gen.groupId = groupId
+	gen.source = s.input[s.groupStart:s.inputPos]
+	err := gen.compile(s2)
 	if err != nil {
 		return nil, err
 	}
-	s.output = s2.output
-	s.patternEntropy = s2.patternEntropy
 	s.lastGroupId = s2.lastGroupId
-	s.groupsOutput[groupId] = s.output[lastOutputSize:]
-	s.lastGen = gen
+	if groupId > 0 {
+		s.definedGroups[groupId] = true
+	}
+	s.addGen(gen)
 	s.buffer = nil
 	return LexRoot, nil
 }
@@ -130,16 +273,31 @@ func processGroupRef(s *State, parentLex LexType) (LexType, error) {
 	if err != nil {
 		return nil, s.errorUnknown("unexpected group id '%v'", string(gid_r))
 	}
-	output, ok := s.groupsOutput[uint64(gid)]
-	if !ok {
+	if !s.definedGroups[uint64(gid)] {
 		s.errorMarkLen = len(gid_r) + 1
 		return nil, s.errorValue("invalid group id '%v'", gid)
 	}
 
-	err = s.addOutput(output)
+	s.addGen(&groupRefGenerator{groupId: uint64(gid)})
+
+	return parentLex, nil
+}
+
+// processNamedGroupRef parses named back-reference \k<name>
+func processNamedGroupRef(s *State, parentLex LexType) (LexType, error) {
+	if s.end() || s.input[s.inputPos] != '<' {
+		return nil, s.errorSyntax("expected '<' after \\k")
+	}
+	s.move(1)
+	name, err := lexGroupName(s)
 	if err != nil {
 		return nil, err
 	}
-
+	groupId, ok := s.groupNames[name]
+	if !ok || !s.definedGroups[groupId] {
+		s.errorMarkLen = len(name) + 4
+		return nil, s.errorValue("invalid group name '%v'", name)
+	}
+	s.addGen(&groupRefGenerator{groupId: groupId, name: name})
 	return parentLex, nil
 }
@@ -0,0 +1,35 @@
+package passgen
+
+// lexUnicodeProperty parses {NAME} after \p or \P, and returns the assigned
+// and printable characters of Unicode script or category NAME, like
+// \p{Greek} or \p{Lu}
+// \P{NAME} is complemented against the universe of negated classes like
+// [^...], which is printable ASCII unless it's changed by Universe of
+// GenerateInput, so \P{L} gives non-letter ASCII characters by default
+func lexUnicodeProperty(s *State, symbol rune) ([]rune, error) {
+	if s.end() || s.input[s.inputPos] != '{' {
+		return nil, s.errorSyntax("expected '{' after \\%c", symbol)
+	}
+	s.move(1)
+	nameRunes := []rune{}
+	for ; !s.end(); s.move(1) {
+		c := s.input[s.inputPos]
+		if c != '}' {
+			nameRunes = append(nameRunes, c)
+			continue
+		}
+		s.move(1)
+		name := string(nameRunes)
+		chars, ok := getUnicodeClass(name, false)
+		if !ok {
+			s.errorMarkLen = len(nameRunes) + 2
+			return nil, s.errorValue("invalid Unicode property %#v", name)
+		}
+		if symbol == 'P' {
+			chars = s.complementChars(chars)
+		}
+		return chars, nil
+	}
+	s.errorOffset++
+	return nil, s.errorSyntax("'{' not closed")
+}
@@ -0,0 +1,74 @@
+package passgen
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	defaultMaxPatternLength = 1000
+	defaultMaxRepeatCount   = 1 << 28
+)
+
+// Limits is the resource limits of compiling a pattern and generating passwords
+// zero value of each field means the default value
+type Limits struct {
+	// MaxOutputLength is the maximum number of runes of a generated password
+	// default is 0, which means no limit
+	MaxOutputLength int
+
+	// TruncateOutput makes generation stop silently when password gets
+	// longer than MaxOutputLength, instead of returning error, which is
+	// the behavior of REPASSGEN_MAX_LENGTH env var of command line
+	TruncateOutput bool
+
+	// MaxPatternLength is the maximum number of runes of pattern
+	// default is 1000
+	MaxPatternLength int
+
+	// MaxRepeatCount is the maximum count of repetition {N} or {M,N}
+	// default is 2^28
+	MaxRepeatCount int64
+
+	// MaxDepth is the maximum nesting depth of groups, alterations and
+	// function calls, default is 0, which means no limit
+	MaxDepth int
+
+	// Context can be used to set a deadline or cancel the generation
+	// default is nil, which means no deadline
+	Context context.Context
+}
+
+func (l *Limits) check() error {
+	if l.MaxOutputLength < 0 {
+		return fmt.Errorf("invalid MaxOutputLength=%d, must not be negative", l.MaxOutputLength)
+	}
+	if l.MaxPatternLength < 0 {
+		return fmt.Errorf("invalid MaxPatternLength=%d, must not be negative", l.MaxPatternLength)
+	}
+	if l.MaxRepeatCount < 0 {
+		return fmt.Errorf("invalid MaxRepeatCount=%d, must not be negative", l.MaxRepeatCount)
+	}
+	if l.MaxDepth < 0 {
+		return fmt.Errorf("invalid MaxDepth=%d, must not be negative", l.MaxDepth)
+	}
+	return nil
+}
+
+func (l *Limits) maxPatternLength() int {
+	if l.MaxPatternLength == 0 {
+		return defaultMaxPatternLength
+	}
+	return l.MaxPatternLength
+}
+
+// apply sets the limits on the shared state
+func (l *Limits) apply(ss *SharedState) {
+	ss.maxOutputLength = l.MaxOutputLength
+	ss.truncateOutput = l.TruncateOutput
+	if l.MaxRepeatCount > 0 {
+		ss.maxRepeatCount = l.MaxRepeatCount
+	}
+	ss.maxDepth = l.MaxDepth
+	ss.ctx = l.Context
+}
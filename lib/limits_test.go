@@ -0,0 +1,157 @@
+package passgen_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestLimitsInvalid(t *testing.T) {
+	is := is.New(t)
+	test := func(limits passgen.Limits, errMsg string) {
+		out, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern: []rune(`[a-z]{5}`),
+			Limits:  limits,
+		})
+		is.Nil(out)
+		is.ErrMsg(err, errMsg)
+	}
+	test(passgen.Limits{MaxOutputLength: -1}, "invalid MaxOutputLength=-1, must not be negative")
+	test(passgen.Limits{MaxPatternLength: -1}, "invalid MaxPatternLength=-1, must not be negative")
+	test(passgen.Limits{MaxRepeatCount: -1}, "invalid MaxRepeatCount=-1, must not be negative")
+	test(passgen.Limits{MaxDepth: -1}, "invalid MaxDepth=-1, must not be negative")
+}
+
+func TestLimitsMaxOutputLength(t *testing.T) {
+	is := is.New(t)
+	test := func(pattern string, errMsg string) {
+		out, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern: []rune(pattern),
+			Limits:  passgen.Limits{MaxOutputLength: 10},
+		})
+		is := is.AddMsg("pattern=%#v", pattern)
+		if errMsg == "" {
+			is.NotErr(err)
+			is.NotNil(out)
+			return
+		}
+		is.Nil(out)
+		is.ErrMsg(err, errMsg)
+	}
+	test(`[a-z]{10}`, "")
+	test(`[a-z]{11}`, "password is longer than 10 characters")
+	test(`[a-z]{100000000}`, "password is longer than 10 characters")
+	test(`$hex([a-z]{6})`, "password is longer than 10 characters")
+	test(`$bip39word(10)`, "password is longer than 10 characters")
+	test(`(a{5}){3}`, "password is longer than 10 characters")
+	test(`$distinct([a-z]{20000000})`, "password is longer than 10 characters")
+	test(`$noadjacent([:alnum:]{1000000})`, "password is longer than 10 characters")
+}
+
+func TestLimitsTruncateOutput(t *testing.T) {
+	is := is.New(t)
+	test := func(pattern string, length int) {
+		out, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern: []rune(pattern),
+			Limits:  passgen.Limits{MaxOutputLength: 10, TruncateOutput: true},
+		})
+		is := is.AddMsg("pattern=%#v", pattern)
+		is.NotErr(err)
+		is.Equal(len(out.Password), length)
+	}
+	test(`[a-z]{10}`, 10)
+	// generation stops after output gets longer than limit
+	test(`[a-z]{11}`, 11)
+	test(`[a-z]{100000000}`, 11)
+	test(`(a{5}){3}`, 11)
+	test(`$distinct([a-z]{20})`, 20)
+}
+
+func TestLimitsMaxPatternLength(t *testing.T) {
+	is := is.New(t)
+	{
+		_, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern: []rune(strings.Repeat("a", 1001)),
+			Limits:  passgen.Limits{MaxPatternLength: 2000},
+		})
+		is.NotErr(err)
+	}
+	{
+		_, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern: []rune("abcd"),
+			Limits:  passgen.Limits{MaxPatternLength: 3},
+		})
+		is.ErrMsg(err, "pattern is too long")
+	}
+}
+
+func TestLimitsMaxRepeatCount(t *testing.T) {
+	is := is.New(t)
+	test := func(pattern string, errMsg string) {
+		_, err := passgen.CompileInput(passgen.GenerateInput{
+			Pattern: []rune(pattern),
+			Limits:  passgen.Limits{MaxRepeatCount: 100},
+		})
+		is := is.AddMsg("pattern=%#v", pattern)
+		if errMsg == "" {
+			is.NotErr(err)
+			return
+		}
+		is.ErrMsg(err, errMsg)
+	}
+	test(`a{100}`, "")
+	test(`a{1,100}`, "")
+	test(`a{101}`, "syntax error near index 5: count value is too large")
+	test(`a{1,101}`, "syntax error near index 7: count value is too large")
+}
+
+func TestLimitsMaxDepth(t *testing.T) {
+	is := is.New(t)
+	test := func(pattern string, errMsg string) {
+		_, err := passgen.CompileInput(passgen.GenerateInput{
+			Pattern: []rune(pattern),
+			Limits:  passgen.Limits{MaxDepth: 2},
+		})
+		is := is.AddMsg("pattern=%#v", pattern)
+		if errMsg == "" {
+			is.NotErr(err)
+			return
+		}
+		tErr, ok := err.(*passgen.Error)
+		if !is.True(ok) {
+			return
+		}
+		is.Equal(errMsg, tErr.Message())
+	}
+	test(`((a))`, "")
+	test(`$hex((a))`, "")
+	test(`(((a)))`, "nesting depth is more than 2")
+	test(`$hex($hex($hex(a)))`, "nesting depth is more than 2")
+}
+
+func TestLimitsContext(t *testing.T) {
+	is := is.New(t)
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern: []rune(`[a-z]{10}`),
+			Limits:  passgen.Limits{Context: ctx},
+		})
+		is.True(errors.Is(err, context.Canceled))
+	}
+	{
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern: []rune(`[a-z]{100000000}`),
+			Limits:  passgen.Limits{Context: ctx},
+		})
+		is.True(errors.Is(err, context.DeadlineExceeded))
+	}
+}
@@ -0,0 +1,232 @@
+package passgen
+
+import (
+	"math"
+	"math/big"
+	"slices"
+)
+
+// maxNoAdjacentLength is the maximum length of argument of $noadjacent,
+// since the number of valid outputs is counted for every position
+const maxNoAdjacentLength = 4096
+
+// noAdjacentGenerator is the generator of $noadjacent(PATTERN) that
+// generates PATTERN with no identical adjacent characters, uniformly
+// among all such outputs of PATTERN
+type noAdjacentGenerator struct {
+	root       *RootGenerator
+	argPattern []rune
+
+	// atoms is the partition of all characters of argument by the character
+	// sets they belong to, every character set is a union of some atoms,
+	// and all characters of an atom have the same number of valid suffixes
+	atoms [][]rune
+	// atomOf maps every character to its atom and its index in the atom
+	atomOf map[rune]atomIndex
+	// sets is the list of atoms of every distinct character set
+	sets [][]int
+	// setIndex[i] maps atoms of sets[i] to their index in sets[i]
+	setIndex []map[int]int
+	// slots is the index of character set of every position of output
+	slots []int
+	// counts[i][j] is the number of valid suffixes of output starting at
+	// position i with a character of atom sets[slots[i]][j]
+	counts [][]*big.Int
+	// totals[i] is the number of valid suffixes starting at position i
+	totals []*big.Int
+}
+
+type atomIndex struct {
+	atom  int
+	index int
+}
+
+func newNoAdjacentGenerator(arg []rune) (*noAdjacentGenerator, error) {
+	return &noAdjacentGenerator{
+		argPattern: arg,
+	}, nil
+}
+
+func (g *noAdjacentGenerator) compile(s *State) error {
+	root, err := subCompile(s, g.argPattern)
+	if err != nil {
+		return err
+	}
+	s.errorMarkLen = max(len(g.argPattern), 1)
+	charSets, ok := charSetCounts(root)
+	if !ok {
+		return s.errorValue("noadjacent: argument must only have characters, character classes, non-capturing groups and fixed repetitions")
+	}
+	length, err := checkSetsLength(s, charSets)
+	if err != nil {
+		return err
+	}
+	if length > maxNoAdjacentLength {
+		return s.errorValue("noadjacent: argument is longer than %d characters", maxNoAdjacentLength)
+	}
+	positions, _ := charSlots(root)
+	slots := make([]int, len(positions))
+	setChars := [][]rune{}
+	setByKey := map[string]int{}
+	setByPtr := map[*rune]int{}
+	for i, chars := range positions {
+		index, found := setByPtr[&chars[0]]
+		if !found {
+			sorted := slices.Clone(chars)
+			slices.Sort(sorted)
+			key := string(sorted)
+			index, found = setByKey[key]
+			if !found {
+				index = len(setChars)
+				setByKey[key] = index
+				setChars = append(setChars, chars)
+			}
+			setByPtr[&chars[0]] = index
+		}
+		slots[i] = index
+	}
+	g.buildAtoms(setChars)
+	counts := make([][]*big.Int, len(slots))
+	totals := make([]*big.Int, len(slots)+1)
+	totals[len(slots)] = big.NewInt(1)
+	// counts are calculated backwards: a suffix starting with c is followed
+	// by any valid suffix of next position that does not start with c
+	for i := len(slots) - 1; i >= 0; i-- {
+		atoms := g.sets[slots[i]]
+		counts[i] = make([]*big.Int, len(atoms))
+		total := new(big.Int)
+		for j, atom := range atoms {
+			count := totals[i+1]
+			if i+1 < len(slots) {
+				if k, found := g.setIndex[slots[i+1]][atom]; found {
+					count = new(big.Int).Sub(count, counts[i+1][k])
+				}
+			}
+			counts[i][j] = count
+			total.Add(total, new(big.Int).Mul(count, big.NewInt(int64(len(g.atoms[atom])))))
+		}
+		totals[i] = total
+	}
+	if totals[0].Sign() == 0 {
+		return s.errorValue("noadjacent: argument always has identical adjacent characters")
+	}
+	g.root = root
+	g.slots = slots
+	g.counts = counts
+	g.totals = totals
+	return nil
+}
+
+// buildAtoms sets atoms and sets of generator from distinct character sets
+func (g *noAdjacentGenerator) buildAtoms(setChars [][]rune) {
+	// signature of a character is the list of sets it belongs to
+	signatures := map[rune][]rune{}
+	order := []rune{}
+	for i, chars := range setChars {
+		for _, c := range chars {
+			if _, found := signatures[c]; !found {
+				order = append(order, c)
+			}
+			signatures[c] = append(signatures[c], rune(i))
+		}
+	}
+	g.atoms = [][]rune{}
+	g.atomOf = make(map[rune]atomIndex, len(order))
+	g.sets = make([][]int, len(setChars))
+	g.setIndex = make([]map[int]int, len(setChars))
+	for i := range setChars {
+		g.setIndex[i] = map[int]int{}
+	}
+	atomByKey := map[string]int{}
+	for _, c := range order {
+		signature := signatures[c]
+		key := string(signature)
+		atom, found := atomByKey[key]
+		if !found {
+			atom = len(g.atoms)
+			atomByKey[key] = atom
+			g.atoms = append(g.atoms, nil)
+			for _, set := range signature {
+				g.setIndex[set][atom] = len(g.sets[set])
+				g.sets[set] = append(g.sets[set], atom)
+			}
+		}
+		g.atomOf[c] = atomIndex{atom: atom, index: len(g.atoms[atom])}
+		g.atoms[atom] = append(g.atoms[atom], c)
+	}
+}
+
+func (g *noAdjacentGenerator) Generate(s *State) error {
+	if g.root == nil {
+		err := g.compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	output := make([]rune, len(g.slots))
+	prev := atomIndex{atom: -1}
+	for i, set := range g.slots {
+		// total count of characters that are not equal to previous one
+		total := g.totals[i]
+		if j, found := g.setIndex[set][prev.atom]; found {
+			total = new(big.Int).Sub(total, g.counts[i][j])
+		}
+		r, err := s.randBigInt(total)
+		if err != nil {
+			return err
+		}
+		for j, atom := range g.sets[set] {
+			size := len(g.atoms[atom])
+			if atom == prev.atom {
+				size--
+			}
+			count := g.counts[i][j]
+			weight := new(big.Int).Mul(count, big.NewInt(int64(size)))
+			if r.Cmp(weight) >= 0 {
+				r.Sub(r, weight)
+				continue
+			}
+			index := int(new(big.Int).Quo(r, count).Int64())
+			if atom == prev.atom && index >= prev.index {
+				index++
+			}
+			output[i] = g.atoms[atom][index]
+			prev = atomIndex{atom: atom, index: index}
+			break
+		}
+	}
+	s.addOutput(output)
+	return nil
+}
+
+// bigLog2 returns log2 of positive number x
+func bigLog2(x *big.Int) float64 {
+	shift := max(x.BitLen()-64, 0)
+	top := new(big.Int).Rsh(x, uint(shift))
+	f, _ := new(big.Float).SetInt(top).Float64()
+	return math.Log2(f) + float64(shift)
+}
+
+// Entropy returns log2 of number of passwords that argument can generate
+// with no identical adjacent characters, which are equally likely
+func (g *noAdjacentGenerator) Entropy(s *State) (float64, error) {
+	if g.root == nil {
+		return 0, s.errorUnknown(s_entropy_not_calc)
+	}
+	return bigLog2(g.totals[0]), nil
+}
+
+func (g *noAdjacentGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	if g.root == nil {
+		return nil, s.errorUnknown(s_entropy_not_calc)
+	}
+	argNode, err := g.root.explainEntropy(s)
+	if err != nil {
+		return nil, err
+	}
+	return &EntropyNode{
+		Detail:   "log2 of number of outputs of argument with no identical adjacent characters",
+		Entropy:  bigLog2(g.totals[0]),
+		Children: []*EntropyNode{argNode},
+	}, nil
+}
@@ -0,0 +1,83 @@
+package passgen_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestNoAdjacent(t *testing.T) {
+	is := is.New(t)
+	test := func(pattern string, length int, entropy float64) {
+		is := is.AddMsg("pattern=%#v", pattern)
+		p, err := passgen.Compile([]rune(pattern))
+		is.NotErr(err)
+		is.True(math.Abs(p.Entropy()-entropy) < 0.0001)
+		for range 20 {
+			out, err := p.Generate()
+			is.NotErr(err)
+			is.Equal(len(out.Password), length)
+			for i := 1; i < len(out.Password); i++ {
+				is.AddMsg("password=%#v", string(out.Password)).True(out.Password[i] != out.Password[i-1])
+			}
+		}
+	}
+	test(`$noadjacent([ab]{4})`, 4, 1)
+	test(`$noadjacent([:digit:]{5})`, 5, math.Log2(10*9*9*9*9))
+	test(`$noadjacent(a[ab]{2})`, 3, 0)
+	// 3 * 2 pairs, except "aa" and "bb"
+	test(`$noadjacent([abc][ab])`, 2, 2)
+	test(`$noadjacent([:alnum:]{100})`, 100, math.Log2(62)+99*math.Log2(61))
+	test(`$noadjacent([:alnum:]{1000})`, 1000, math.Log2(62)+999*math.Log2(61))
+}
+
+func TestNoAdjacentUniform(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`$noadjacent([abc][ab])`))
+	is.NotErr(err)
+	counts := map[string]int{}
+	for range 4000 {
+		out, err := p.Generate()
+		is.NotErr(err)
+		counts[string(out.Password)]++
+	}
+	is.Equal(len(counts), 4)
+	for password, count := range counts {
+		is.AddMsg("password=%#v", password).True(count > 800 && count < 1200)
+	}
+}
+
+func TestNoAdjacentError(t *testing.T) {
+	testGenErr(t, &genErrCase{
+		Pattern: `$noadjacent(xaa)`,
+		Error:   `            ^^^ value error: noadjacent: argument always has identical adjacent characters`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$noadjacent(a[ab]b)`,
+		Error:   `            ^^^^^^ value error: noadjacent: argument always has identical adjacent characters`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$noadjacent([:alnum:]{1000000})`,
+		Error:   `            ^^^^^^^^^^^^^^^^^^ value error: noadjacent: argument is longer than 4096 characters`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$noadjacent([a-z]{1,3})`,
+		Error:   `            ^^^^^^^^^^ value error: noadjacent: argument must only have characters, character classes, non-capturing groups and fixed repetitions`,
+	})
+}
+
+func TestNoAdjacentPolicy(t *testing.T) {
+	is := is.New(t)
+	policy := &passgen.Policy{MinLength: 12, MaxRepeat: 1}
+	p, err := passgen.Compile([]rune(`$noadjacent([:alnum:]{12})`))
+	is.NotErr(err)
+	is.NotErr(p.CheckPolicy(policy))
+	p, err = passgen.Compile([]rune(`$distinct([:alnum:]{12})`))
+	is.NotErr(err)
+	is.NotErr(p.CheckPolicy(policy))
+	p, err = passgen.Compile([]rune(`[:alnum:]{12}`))
+	is.NotErr(err)
+	is.Err(p.CheckPolicy(policy))
+}
@@ -1,38 +1,48 @@
 package passgen
 
-import (
-	"crypto/rand"
-	"math/big"
-)
-
 type onceOrNoneGenerator struct {
+	root    *RootGenerator
 	pattern []rune
-	entropy float64
 }
 
-func randBool() bool {
-	randBig, err := rand.Int(rand.Reader, big.NewInt(10))
+func randBool(s *State) (bool, error) {
+	i, err := s.randInt(2)
+	if err != nil {
+		return false, err
+	}
+	return i == 1, nil
+}
+
+func (g *onceOrNoneGenerator) compile(s *State) error {
+	root, err := subCompile(s, g.pattern)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	return randBig.Int64()%2 == 1
+	g.root = root
+	return nil
 }
 
 func (g *onceOrNoneGenerator) Generate(s *State) error {
-	if randBool() {
-		output, err := subGenerate(s, g.pattern)
+	if g.root == nil {
+		err := g.compile(s)
 		if err != nil {
 			return err
 		}
-		s.output = append(s.output, output...)
 	}
-	s.patternEntropy += 1.0
-	g.entropy = s.patternEntropy
+	include, err := randBool(s)
+	if err != nil {
+		return err
+	}
+	if include {
+		return g.root.Generate(s)
+	}
 	return nil
 }
 
+// Entropy returns 1 bit, for choosing between the pattern and empty string
+// like alteration, the minimum entropy of both choices (zero) is added
 func (g *onceOrNoneGenerator) Entropy(_ *State) (float64, error) {
-	return g.entropy, nil
+	return 1, nil
 }
 
 func newOnceOrNoneGenerator(pattern []rune) (*onceOrNoneGenerator, error) {
@@ -40,3 +50,11 @@ func newOnceOrNoneGenerator(pattern []rune) (*onceOrNoneGenerator, error) {
 		pattern: pattern,
 	}, nil
 }
+
+func (g *onceOrNoneGenerator) explainEntropy(_ *State) (*EntropyNode, error) {
+	return &EntropyNode{
+		Detail:  "1 bit for including or omitting",
+		Size:    2,
+		Entropy: 1,
+	}, nil
+}
@@ -0,0 +1,157 @@
+package passgen
+
+import (
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Pattern is a compiled pattern, that can be used to generate many passwords
+// without parsing the pattern again
+type Pattern struct {
+	pattern []rune
+	root    *RootGenerator
+	entropy float64
+	rand    io.Reader
+	limits  Limits
+	uniform bool
+
+	// universe is the set of characters for negated character classes
+	universe []rune
+
+	// exclude is the characters that are removed from character classes
+	exclude []rune
+
+	// charClasses is the named character classes of GenerateInput
+	charClasses map[string][]rune
+
+	// groupNames maps name of named groups to group id
+	groupNames map[string]uint64
+}
+
+// Compile parses the given pattern and returns a Pattern
+// all syntax and value errors of pattern are reported here, before
+// any password is generated
+func Compile(pattern []rune) (*Pattern, error) {
+	return CompileInput(GenerateInput{Pattern: pattern})
+}
+
+// CompileInput is like Compile, but also uses Rand, Limits, Uniform,
+// Universe, Exclude, AllowFiles and FileDir of given input
+func CompileInput(in GenerateInput) (*Pattern, error) {
+	p, _, err := compileInput(in)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func compileInput(in GenerateInput) (*Pattern, *State, error) {
+	limits := in.Limits
+	err := limits.check()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(in.Pattern) > limits.maxPatternLength() {
+		return nil, nil, fmt.Errorf("pattern is too long")
+	}
+	if limits.Context != nil && limits.Context.Err() != nil {
+		return nil, nil, limits.Context.Err()
+	}
+	charClasses, err := checkCharClasses(in.CharClasses)
+	if err != nil {
+		return nil, nil, err
+	}
+	ss := NewSharedState()
+	limits.apply(ss)
+	ss.uniform = in.Uniform
+	ss.universe = in.Universe
+	ss.exclude = in.Exclude
+	ss.charClasses = charClasses
+	ss.allowFiles = in.AllowFiles
+	ss.fileDir = in.FileDir
+	s := NewState(ss, in.Pattern)
+	root := NewRootGenerator()
+	err = root.compile(s)
+	if err != nil {
+		return nil, s, err
+	}
+	entropy, err := root.Entropy(s)
+	if err != nil {
+		return nil, s, err
+	}
+	return &Pattern{
+		pattern: in.Pattern,
+		root:    root,
+		entropy: entropy,
+		rand:    in.Rand,
+		limits:  limits,
+		uniform: in.Uniform,
+
+		universe:    in.Universe,
+		exclude:     in.Exclude,
+		charClasses: charClasses,
+		groupNames:  ss.groupNames,
+	}, s, nil
+}
+
+// WithRand returns a copy of Pattern that uses r as the source of random bytes
+// if r is nil, crypto/rand.Reader is used
+func (p *Pattern) WithRand(r io.Reader) *Pattern {
+	p2 := *p
+	p2.rand = r
+	return &p2
+}
+
+func (p *Pattern) generate() (*GenerateOutput, *State, error) {
+	ss := NewSharedState()
+	p.limits.apply(ss)
+	ss.uniform = p.uniform
+	ss.universe = p.universe
+	ss.exclude = p.exclude
+	ss.charClasses = p.charClasses
+	if p.rand != nil {
+		ss.rand = p.rand
+	}
+	s := NewState(ss, p.pattern)
+	err := p.root.Generate(s)
+	if err != nil {
+		return nil, s, err
+	}
+	return &GenerateOutput{
+		Password:       s.output,
+		PatternEntropy: p.entropy,
+		Groups:         p.namedGroupsOutput(ss),
+	}, s, nil
+}
+
+func (p *Pattern) namedGroupsOutput(ss *SharedState) map[string][]rune {
+	if len(p.groupNames) == 0 {
+		return nil
+	}
+	groups := make(map[string][]rune, len(p.groupNames))
+	for name, groupId := range p.groupNames {
+		output, ok := ss.groupsOutput[groupId]
+		if !ok {
+			continue
+		}
+		groups[name] = slices.Clone(output)
+	}
+	return groups
+}
+
+// Generate generates a random password
+func (p *Pattern) Generate() (*GenerateOutput, error) {
+	out, _, err := p.generate()
+	return out, err
+}
+
+// Entropy returns the entropy of pattern in bits
+func (p *Pattern) Entropy() float64 {
+	return p.entropy
+}
+
+// String returns the pattern string
+func (p *Pattern) String() string {
+	return string(p.pattern)
+}
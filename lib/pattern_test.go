@@ -0,0 +1,102 @@
+package passgen_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestCompile(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`([a-z]{5}[1-9]{2})-\1`))
+	is.NotErr(err)
+	is.Equal(`([a-z]{5}[1-9]{2})-\1`, p.String())
+	isFloatBetween(is, p.Entropy(), 29.8, 29.9)
+	passwords := map[string]bool{}
+	for range 10 {
+		out, err := p.Generate()
+		is.NotErr(err)
+		pwStr := string(out.Password)
+		is.Equal(15, len(pwStr))
+		parts := strings.Split(pwStr, "-")
+		is.Equal(2, len(parts))
+		is.Equal(parts[0], parts[1])
+		is.Equal(p.Entropy(), out.PatternEntropy)
+		passwords[pwStr] = true
+	}
+	is.True(len(passwords) > 1)
+}
+
+func TestCompileRange(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`[a-z]{2,4}`))
+	is.NotErr(err)
+	lengths := map[int]bool{}
+	for range 100 {
+		out, err := p.Generate()
+		is.NotErr(err)
+		lengths[len(out.Password)] = true
+	}
+	is.Equal(map[int]bool{2: true, 3: true, 4: true}, lengths)
+}
+
+func TestCompileError(t *testing.T) {
+	is := is.New(t)
+	compileErr := func(pattern string) *passgen.Error {
+		p, err := passgen.Compile([]rune(pattern))
+		is.Nil(p)
+		tErr, ok := err.(*passgen.Error)
+		if !ok {
+			t.Fatalf("pattern=%#v: unexpected error %v", pattern, err)
+		}
+		return tErr
+	}
+	// errors inside $?(...) and alteration branches used to depend on
+	// which choice was randomly generated
+	is.Equal(
+		`    ^^^^^ value error: invalid character class "foo"`,
+		compileErr(`$?([:foo:])`).SpacedError(),
+	)
+	is.Equal(
+		`invalid character class "foo"`,
+		compileErr(`(abc|[:foo:])`).Message(),
+	)
+	is.Equal(
+		`            ^ syntax error: '[' not closed`,
+		compileErr(`[a-z]{1000}[`).SpacedError(),
+	)
+}
+
+func TestCompileGenerateError(t *testing.T) {
+	is := is.New(t)
+	// error of encoder functions depend on generated value
+	p, err := passgen.Compile([]rune(`$base64([g])`))
+	is.NotErr(err)
+	_, err = p.Generate()
+	tErr, ok := err.(*passgen.Error)
+	if !is.True(ok) {
+		return
+	}
+	is.Equal(`          ^ value error: invalid hex number "g"`, tErr.SpacedError())
+}
+
+func TestCompileRangeEntropy(t *testing.T) {
+	is := is.New(t)
+	// log2(5) + 12*log2(52), because lengths are chosen with equal probability
+	for range 10 {
+		p, err := passgen.Compile([]rune(`[:alpha:]{12,16}`))
+		is.NotErr(err)
+		isFloatBetween(is, p.Entropy(), 70.727, 70.728)
+	}
+	// log2(52^12 + 52^13 + ... + 52^16)
+	for range 10 {
+		p, err := passgen.CompileInput(passgen.GenerateInput{
+			Pattern: []rune(`[:alpha:]{12,16}`),
+			Uniform: true,
+		})
+		is.NotErr(err)
+		isFloatBetween(is, p.Entropy(), 91.235, 91.236)
+	}
+}
@@ -0,0 +1,419 @@
+package passgen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// defaultPolicyLength is the length of passwords generated from a policy
+// that is used if it's allowed by MinLength and MaxLength of policy
+const defaultPolicyLength = 16
+
+// Policy is a password policy, like the rules of a vendor for passwords
+// it can be used to check that a pattern can only generate compliant
+// passwords (see Pattern.CheckPolicy), or to generate compliant passwords
+// directly (see Policy.Pattern and Pattern.GeneratePolicy)
+type Policy struct {
+	// MinLength is the minimum number of characters, 0 means no minimum
+	MinLength int `json:"min_length" toml:"min_length"`
+
+	// MaxLength is the maximum number of characters, 0 means no maximum
+	MaxLength int `json:"max_length" toml:"max_length"`
+
+	// Charset is the character class of allowed characters, like
+	// "[:graph:]" or "[a-zA-Z0-9_]", all characters are allowed if empty
+	// printable ASCII characters "[:graph:]" are used to generate
+	// passwords if empty
+	Charset string `json:"charset" toml:"charset"`
+
+	// Require is the list of character classes that password must have
+	// at least one character of, like ["[:upper:]", "[:digit:]"]
+	Require []string `json:"require" toml:"require"`
+
+	// Forbidden is the characters that are not allowed in password
+	Forbidden string `json:"forbidden" toml:"forbidden"`
+
+	// MaxRepeat is the maximum number of identical consecutive characters
+	// 0 means no limit
+	MaxRepeat int `json:"max_repeat" toml:"max_repeat"`
+}
+
+const (
+	PolicyRuleMinLength = "min_length"
+	PolicyRuleMaxLength = "max_length"
+	PolicyRuleCharset   = "charset"
+	PolicyRuleRequire   = "require"
+	PolicyRuleForbidden = "forbidden"
+	PolicyRuleMaxRepeat = "max_repeat"
+)
+
+// PolicyViolation is the error of a pattern or password that does not
+// comply with a rule of policy
+type PolicyViolation struct {
+	// Rule is the name of rule in policy file, like "min_length"
+	Rule string
+	Msg  string
+}
+
+func (e *PolicyViolation) Error() string {
+	return fmt.Sprintf("policy %s: %s", e.Rule, e.Msg)
+}
+
+func newPolicyViolation(rule string, msg string, args ...any) *PolicyViolation {
+	return &PolicyViolation{
+		Rule: rule,
+		Msg:  fmt.Sprintf(msg, args...),
+	}
+}
+
+// policyRules is the compiled rules of a Policy
+type policyRules struct {
+	policy    *Policy
+	charset   map[rune]bool
+	require   []map[rune]bool
+	forbidden map[rune]bool
+}
+
+// compileCharClassRule compiles the character class of a policy rule
+func compileCharClassRule(rule string, pattern string) ([]rune, error) {
+	ss := NewSharedState()
+	s := NewState(ss, []rune(pattern))
+	root := NewRootGenerator()
+	err := root.compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %#v: %w", rule, pattern, err)
+	}
+	chars := singleCharClass(root)
+	if len(chars) == 0 {
+		return nil, fmt.Errorf("policy %s: %#v is not a character class", rule, pattern)
+	}
+	return chars, nil
+}
+
+func (policy *Policy) compile() (*policyRules, error) {
+	if policy.MinLength < 0 {
+		return nil, fmt.Errorf("policy %s: must not be negative", PolicyRuleMinLength)
+	}
+	if policy.MaxLength < 0 {
+		return nil, fmt.Errorf("policy %s: must not be negative", PolicyRuleMaxLength)
+	}
+	if policy.MaxLength > 0 && policy.MaxLength < policy.MinLength {
+		return nil, fmt.Errorf("policy %s: must not be less than %s", PolicyRuleMaxLength, PolicyRuleMinLength)
+	}
+	if policy.MaxRepeat < 0 {
+		return nil, fmt.Errorf("policy %s: must not be negative", PolicyRuleMaxRepeat)
+	}
+	rules := &policyRules{
+		policy:    policy,
+		forbidden: runeSet([]rune(policy.Forbidden)),
+	}
+	if policy.Charset != "" {
+		chars, err := compileCharClassRule(PolicyRuleCharset, policy.Charset)
+		if err != nil {
+			return nil, err
+		}
+		rules.charset = runeSet(chars)
+	}
+	for _, pattern := range policy.Require {
+		chars, err := compileCharClassRule(PolicyRuleRequire, pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules.require = append(rules.require, runeSet(chars))
+	}
+	return rules, nil
+}
+
+func (rules *policyRules) allowed(c rune) bool {
+	if rules.forbidden[c] {
+		return false
+	}
+	return rules.charset == nil || rules.charset[c]
+}
+
+// CheckPassword returns a *PolicyViolation error if password does not comply
+// with policy, or an error if policy is invalid
+func (policy *Policy) CheckPassword(password []rune) error {
+	rules, err := policy.compile()
+	if err != nil {
+		return err
+	}
+	return rules.checkPassword(password)
+}
+
+func (rules *policyRules) checkPassword(password []rune) error {
+	policy := rules.policy
+	if len(password) < policy.MinLength {
+		return newPolicyViolation(PolicyRuleMinLength, "password has %d characters, minimum is %d", len(password), policy.MinLength)
+	}
+	if policy.MaxLength > 0 && len(password) > policy.MaxLength {
+		return newPolicyViolation(PolicyRuleMaxLength, "password has %d characters, maximum is %d", len(password), policy.MaxLength)
+	}
+	for _, c := range password {
+		if rules.forbidden[c] {
+			return newPolicyViolation(PolicyRuleForbidden, "password has forbidden character %s", strconv.QuoteRune(c))
+		}
+		if rules.charset != nil && !rules.charset[c] {
+			return newPolicyViolation(PolicyRuleCharset, "password has character %s which is not in %s", strconv.QuoteRune(c), policy.Charset)
+		}
+	}
+	for i, chars := range rules.require {
+		found := false
+		for _, c := range password {
+			if chars[c] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return newPolicyViolation(PolicyRuleRequire, "password has no character of %s", policy.Require[i])
+		}
+	}
+	if policy.MaxRepeat > 0 {
+		run := 0
+		for i, c := range password {
+			if i > 0 && c == password[i-1] {
+				run++
+			} else {
+				run = 1
+			}
+			if run > policy.MaxRepeat {
+				return newPolicyViolation(PolicyRuleMaxRepeat, "password has %d identical consecutive characters, maximum is %d", run, policy.MaxRepeat)
+			}
+		}
+	}
+	return nil
+}
+
+// escapeRangeChar returns the character as it can be used inside [...]
+func escapeRangeChar(c rune) string {
+	switch c {
+	case '\\', '[', ']', '-', '^', ':', '&':
+		return `\` + string(c)
+	case '\t':
+		return `\t`
+	case '\n':
+		return `\n`
+	case '\r':
+		return `\r`
+	}
+	return string(c)
+}
+
+// Pattern returns a pattern that generates passwords complying with
+// policy, except for MaxRepeat, which is checked by Pattern.GeneratePolicy
+func (policy *Policy) Pattern() (string, error) {
+	rules, err := policy.compile()
+	if err != nil {
+		return "", err
+	}
+	var chars []rune
+	if rules.charset == nil {
+		chars, _ = getCharClass("graph")
+	} else {
+		chars, _ = compileCharClassRule(PolicyRuleCharset, policy.Charset)
+	}
+	var class strings.Builder
+	class.WriteString("[")
+	count := 0
+	for _, c := range chars {
+		if !rules.allowed(c) {
+			continue
+		}
+		class.WriteString(escapeRangeChar(c))
+		count++
+	}
+	class.WriteString("]")
+	if count == 0 {
+		return "", newPolicyViolation(PolicyRuleForbidden, "all characters are forbidden")
+	}
+	length := max(policy.MinLength, defaultPolicyLength)
+	if policy.MaxLength > 0 {
+		length = min(length, policy.MaxLength)
+	}
+	pattern := class.String() + "{" + strconv.Itoa(length) + "}"
+	if len(policy.Require) == 0 {
+		return pattern, nil
+	}
+	return "$require(" + pattern + "," + strings.Join(policy.Require, ",") + ")", nil
+}
+
+// GeneratePolicy generates a password that complies with policy
+// passwords that do not comply are generated again (rejection sampling)
+// so it can be used with the pattern of Policy.Pattern
+// entropy of output includes log2 of probability of complying with
+// MaxRepeat for the pattern of Policy.Pattern, for other patterns
+// it's an upper bound
+func (p *Pattern) GeneratePolicy(policy *Policy) (*GenerateOutput, error) {
+	rules, err := policy.compile()
+	if err != nil {
+		return nil, err
+	}
+	probability := 1.0
+	if policy.MaxRepeat > 0 {
+		probability, _ = maxRepeatProbability(p.root, policy.MaxRepeat)
+		if probability <= 0 {
+			return nil, newPolicyViolation(PolicyRuleMaxRepeat, "pattern can never comply with max_repeat")
+		}
+	}
+	var lastErr error
+	for range requireMaxAttempts {
+		out, err := p.Generate()
+		if err != nil {
+			return nil, err
+		}
+		lastErr = rules.checkPassword(out.Password)
+		if lastErr == nil {
+			out.PatternEntropy += math.Log2(probability)
+			return out, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// ExplainPolicyEntropy returns the tree of entropy breakdown of pattern
+// like ExplainEntropy, with the entropy of GeneratePolicy for policy
+// which includes log2 of probability of complying with MaxRepeat
+func (p *Pattern) ExplainPolicyEntropy(policy *Policy) (*EntropyNode, error) {
+	_, err := policy.compile()
+	if err != nil {
+		return nil, err
+	}
+	node, err := p.ExplainEntropy()
+	if err != nil {
+		return nil, err
+	}
+	if policy.MaxRepeat <= 0 {
+		return node, nil
+	}
+	probability, _ := maxRepeatProbability(p.root, policy.MaxRepeat)
+	if probability <= 0 {
+		return nil, newPolicyViolation(PolicyRuleMaxRepeat, "pattern can never comply with max_repeat")
+	}
+	return &EntropyNode{
+		Kind:    EntropyKindPolicy,
+		Pattern: node.Pattern,
+		Detail: fmt.Sprintf(
+			"max_repeat=%d, log2 of probability %.4g of complying",
+			policy.MaxRepeat,
+			probability,
+		),
+		Entropy:  node.Entropy + math.Log2(probability),
+		Children: []*EntropyNode{node},
+	}, nil
+}
+
+// repeatState is the state of maxRepeatProbability after some characters
+type repeatState struct {
+	// seen is the bit mask of requirements that are satisfied
+	seen uint64
+	// last is the bit mask of requirements of last character
+	last uint64
+	// run is the number of identical consecutive characters at the end
+	run int
+}
+
+// maxRepeatProbability returns the probability that output of root has at
+// most maxRepeat identical consecutive characters, given that it satisfies
+// the requirements if root is a $require call, or false if root is not
+// a fixed repetition of one character class (optionally inside $require)
+func maxRepeatProbability(root *RootGenerator, maxRepeat int) (float64, bool) {
+	var requirements []map[rune]bool
+	if len(root.children) == 1 {
+		if call, ok := root.children[0].(*functionCallGenerator); ok {
+			req, ok := call.gen.(*requireGenerator)
+			if !ok || req.root == nil || len(req.requirements) > 16 {
+				return 1, false
+			}
+			root = req.root
+			requirements = req.requirements
+		}
+	}
+	sets, ok := charSetCounts(root)
+	if !ok || len(sets) != 1 {
+		return 1, false
+	}
+	chars, length := sets[0].chars, sets[0].count
+	if length > 1<<12 {
+		return 1, false
+	}
+	// characters are grouped by the requirements they satisfy, since
+	// characters of a group are interchangeable
+	groupSize := map[uint64]float64{}
+	for _, c := range chars {
+		mask := uint64(0)
+		for i, req := range requirements {
+			if req[c] {
+				mask |= 1 << i
+			}
+		}
+		groupSize[mask]++
+	}
+	n := float64(len(chars))
+	full := uint64(1)<<len(requirements) - 1
+	// probability returns the probability of satisfying requirements and
+	// having runs of at most limit characters, or any runs if limit is 0
+	probability := func(limit int) float64 {
+		states := map[repeatState]float64{}
+		for mask, size := range groupSize {
+			states[repeatState{seen: mask, last: mask, run: 1}] += size / n
+		}
+		for range length - 1 {
+			next := make(map[repeatState]float64, len(states))
+			for state, p := range states {
+				if limit == 0 {
+					next[state] += p / n
+				} else if state.run < limit {
+					same := state
+					same.run++
+					next[same] += p / n
+				}
+				for mask, size := range groupSize {
+					if mask == state.last {
+						size--
+					}
+					if size <= 0 {
+						continue
+					}
+					next[repeatState{seen: state.seen | mask, last: mask, run: 1}] += p * size / n
+				}
+			}
+			states = next
+		}
+		total := 0.0
+		for state, p := range states {
+			if state.seen == full {
+				total += p
+			}
+		}
+		return total
+	}
+	both := probability(maxRepeat)
+	if len(requirements) == 0 {
+		return both, true
+	}
+	required := probability(0)
+	if required <= 0 {
+		return 1, false
+	}
+	return both / required, true
+}
+
+// CheckPolicy checks that every password that pattern can generate complies
+// with policy, and returns the joined *PolicyViolation errors of all rules
+// that are violated
+func (p *Pattern) CheckPolicy(policy *Policy) error {
+	rules, err := policy.compile()
+	if err != nil {
+		return err
+	}
+	a := newPolicyAnalyzer(rules)
+	info, err := a.analyze(p.root)
+	if err != nil {
+		return err
+	}
+	return errors.Join(a.violations(info)...)
+}
@@ -0,0 +1,495 @@
+package passgen
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+)
+
+var errPolicyUnsupported = errors.New("policy can not be checked for pattern")
+
+// maxAnalysisLength is the saturation limit of lengths in policy analysis
+const maxAnalysisLength = math.MaxInt64 / 2
+
+func satAdd(a int64, b int64) int64 {
+	return min(a+b, maxAnalysisLength)
+}
+
+func satMul(a int64, b int64) int64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a > maxAnalysisLength/b {
+		return maxAnalysisLength
+	}
+	return a * b
+}
+
+// runInfo is the analysis of runs of identical consecutive characters
+// of all outputs of a generator, all values are upper bounds
+type runInfo struct {
+	maxRun int64
+	// prefix is the length of leading run of each character
+	prefix map[rune]int64
+	// suffix is the length of trailing run of each character
+	suffix map[rune]int64
+	// full is the length of non-empty outputs that only have one character
+	full map[rune]int64
+}
+
+func newRunInfo() *runInfo {
+	return &runInfo{
+		prefix: map[rune]int64{},
+		suffix: map[rune]int64{},
+		full:   map[rune]int64{},
+	}
+}
+
+func (r *runInfo) equal(other *runInfo) bool {
+	return r.maxRun == other.maxRun &&
+		maps.Equal(r.prefix, other.prefix) &&
+		maps.Equal(r.suffix, other.suffix) &&
+		maps.Equal(r.full, other.full)
+}
+
+// patternInfo is the result of static analysis of a generator, that
+// covers every password it can generate
+type patternInfo struct {
+	minLen int64
+	maxLen int64
+	chars  map[rune]bool
+	// has[i] is true if every output has a character of i'th required class
+	has []bool
+	// runs is nil if policy has no MaxRepeat
+	runs *runInfo
+}
+
+// policyAnalyzer checks rules of a policy on a compiled pattern statically
+type policyAnalyzer struct {
+	rules *policyRules
+	// groups is the analysis of captured groups, for group references
+	groups map[uint64]*patternInfo
+	// runCap is the saturation limit of run lengths
+	runCap int64
+}
+
+func newPolicyAnalyzer(rules *policyRules) *policyAnalyzer {
+	return &policyAnalyzer{
+		rules:  rules,
+		groups: map[uint64]*patternInfo{},
+		runCap: int64(rules.policy.MaxRepeat) + 1,
+	}
+}
+
+func (a *policyAnalyzer) emptyInfo() *patternInfo {
+	info := &patternInfo{
+		chars: map[rune]bool{},
+		has:   make([]bool, len(a.rules.require)),
+	}
+	if a.rules.policy.MaxRepeat > 0 {
+		info.runs = newRunInfo()
+	}
+	return info
+}
+
+// charInfo is the analysis of a single character chosen from chars
+func (a *policyAnalyzer) charInfo(chars []rune) *patternInfo {
+	info := a.emptyInfo()
+	info.minLen = 1
+	info.maxLen = 1
+	for _, c := range chars {
+		info.chars[c] = true
+	}
+	for i, required := range a.rules.require {
+		info.has[i] = true
+		for _, c := range chars {
+			if !required[c] {
+				info.has[i] = false
+				break
+			}
+		}
+	}
+	if info.runs != nil {
+		info.runs.maxRun = 1
+		for _, c := range chars {
+			info.runs.prefix[c] = 1
+			info.runs.suffix[c] = 1
+			info.runs.full[c] = 1
+		}
+	}
+	return info
+}
+
+func (a *policyAnalyzer) seqRuns(x *runInfo, xEmpty bool, y *runInfo, yEmpty bool) *runInfo {
+	fullOf := func(r *runInfo, empty bool, c rune) (int64, bool) {
+		n, ok := r.full[c]
+		if ok {
+			return n, true
+		}
+		return 0, empty
+	}
+	r := newRunInfo()
+	r.maxRun = max(x.maxRun, y.maxRun)
+	for c, n := range x.suffix {
+		if m, ok := y.prefix[c]; ok {
+			r.maxRun = max(r.maxRun, min(n+m, a.runCap))
+		}
+	}
+	maps.Copy(r.prefix, x.prefix)
+	for c, m := range y.prefix {
+		if n, ok := fullOf(x, xEmpty, c); ok {
+			r.prefix[c] = max(r.prefix[c], min(n+m, a.runCap))
+		}
+	}
+	maps.Copy(r.suffix, y.suffix)
+	for c, m := range x.suffix {
+		if n, ok := fullOf(y, yEmpty, c); ok {
+			r.suffix[c] = max(r.suffix[c], min(n+m, a.runCap))
+		}
+	}
+	for _, full := range []map[rune]int64{x.full, y.full} {
+		for c := range full {
+			n, okx := fullOf(x, xEmpty, c)
+			m, oky := fullOf(y, yEmpty, c)
+			if okx && oky {
+				r.full[c] = min(n+m, a.runCap)
+			}
+		}
+	}
+	return r
+}
+
+func altRuns(x *runInfo, y *runInfo) *runInfo {
+	if x == nil {
+		return y
+	}
+	r := newRunInfo()
+	r.maxRun = max(x.maxRun, y.maxRun)
+	for _, pair := range [][3]map[rune]int64{
+		{r.prefix, x.prefix, y.prefix},
+		{r.suffix, x.suffix, y.suffix},
+		{r.full, x.full, y.full},
+	} {
+		maps.Copy(pair[0], pair[1])
+		for c, n := range pair[2] {
+			pair[0][c] = max(pair[0][c], n)
+		}
+	}
+	return r
+}
+
+// seq returns the analysis of x followed by y
+func (a *policyAnalyzer) seq(x *patternInfo, y *patternInfo) *patternInfo {
+	info := &patternInfo{
+		minLen: satAdd(x.minLen, y.minLen),
+		maxLen: satAdd(x.maxLen, y.maxLen),
+		chars:  maps.Clone(x.chars),
+		has:    make([]bool, len(x.has)),
+	}
+	maps.Copy(info.chars, y.chars)
+	for i := range info.has {
+		info.has[i] = x.has[i] || y.has[i]
+	}
+	if x.runs != nil {
+		info.runs = a.seqRuns(x.runs, x.minLen == 0, y.runs, y.minLen == 0)
+	}
+	return info
+}
+
+// alt returns the analysis of either x or y
+func (a *policyAnalyzer) alt(x *patternInfo, y *patternInfo) *patternInfo {
+	if x == nil {
+		return y
+	}
+	info := &patternInfo{
+		minLen: min(x.minLen, y.minLen),
+		maxLen: max(x.maxLen, y.maxLen),
+		chars:  maps.Clone(x.chars),
+		has:    make([]bool, len(x.has)),
+	}
+	maps.Copy(info.chars, y.chars)
+	for i := range info.has {
+		info.has[i] = x.has[i] && y.has[i]
+	}
+	if x.runs != nil {
+		info.runs = altRuns(x.runs, y.runs)
+	}
+	return info
+}
+
+func (a *policyAnalyzer) repeat(child *patternInfo, minCount int64, maxCount int64) *patternInfo {
+	info := a.emptyInfo()
+	info.minLen = satMul(child.minLen, minCount)
+	info.maxLen = satMul(child.maxLen, maxCount)
+	if maxCount > 0 {
+		info.chars = child.chars
+	}
+	if minCount > 0 {
+		copy(info.has, child.has)
+	}
+	if child.runs == nil {
+		return info
+	}
+	// runs of child repeated k times, for k in [minCount, maxCount]
+	// until it does not change anymore
+	var union *runInfo
+	current := newRunInfo()
+	if minCount == 0 {
+		union = current
+	}
+	for k := int64(1); k <= maxCount; k++ {
+		next := a.seqRuns(current, k == 1 || child.minLen == 0, child.runs, child.minLen == 0)
+		if k >= minCount {
+			union = altRuns(union, next)
+		}
+		if k >= max(minCount, 2) && next.equal(current) {
+			break
+		}
+		current = next
+	}
+	info.runs = union
+	return info
+}
+
+// shuffled returns the analysis of shuffled output of root
+// any character can be repeated as many times as it occurs in output
+func (a *policyAnalyzer) shuffled(root *RootGenerator, child *patternInfo) *patternInfo {
+	if child.runs == nil {
+		return child
+	}
+	occurrences := map[rune]int64{}
+	slots, ok := shuffleSlots(root)
+	if ok {
+		for _, slot := range slots {
+			for _, c := range slot.chars {
+				occurrences[c] = satAdd(occurrences[c], slot.count)
+			}
+		}
+	} else {
+		for c := range child.chars {
+			occurrences[c] = child.maxLen
+		}
+	}
+	info := *child
+	info.runs = newRunInfo()
+	for c, count := range occurrences {
+		run := min(count, a.runCap)
+		info.runs.maxRun = max(info.runs.maxRun, run)
+		info.runs.prefix[c] = run
+		info.runs.suffix[c] = run
+		info.runs.full[c] = run
+	}
+	return &info
+}
+
+func (a *policyAnalyzer) analyze(gen GeneratorIface) (*patternInfo, error) {
+	switch g := gen.(type) {
+	case *RootGenerator:
+		info := a.emptyInfo()
+		for _, child := range g.children {
+			childInfo, err := a.analyze(child)
+			if err != nil {
+				return nil, err
+			}
+			info = a.seq(info, childInfo)
+		}
+		return info, nil
+	case *staticStringGenerator:
+		info := a.emptyInfo()
+		for _, c := range g.str {
+			info = a.seq(info, a.charInfo([]rune{c}))
+		}
+		return info, nil
+	case *charClassGenerator:
+		info := a.emptyInfo()
+		for _, chars := range g.charClasses {
+			if len(chars) > 0 {
+				info = a.seq(info, a.charInfo(chars))
+			}
+		}
+		return info, nil
+	case *repeatGenerator:
+		child, err := a.analyze(g.child)
+		if err != nil {
+			return nil, err
+		}
+		return a.repeat(child, g.minCount, g.maxCount), nil
+	case *groupGenerator:
+		info, err := a.analyze(g.root)
+		if err != nil {
+			return nil, err
+		}
+		if g.groupId > 0 {
+			a.groups[g.groupId] = info
+		}
+		return info, nil
+	case *groupRefGenerator:
+		info, ok := a.groups[g.groupId]
+		if !ok {
+			return a.emptyInfo(), nil
+		}
+		return info, nil
+	case *alterGenerator:
+		var info *patternInfo
+		for _, root := range g.roots {
+			branch, err := a.analyze(root)
+			if err != nil {
+				return nil, err
+			}
+			info = a.alt(info, branch)
+		}
+		if g.groupId > 0 {
+			a.groups[g.groupId] = info
+		}
+		return info, nil
+	case *weightedGenerator:
+		var info *patternInfo
+		for _, root := range g.roots {
+			branch, err := a.analyze(root)
+			if err != nil {
+				return nil, err
+			}
+			info = a.alt(info, branch)
+		}
+		return info, nil
+	case *onceOrNoneGenerator:
+		info, err := a.analyze(g.root)
+		if err != nil {
+			return nil, err
+		}
+		return a.alt(a.emptyInfo(), info), nil
+	case *useGenerator:
+		return a.analyze(g.root)
+	case *shuffleGenerator:
+		info, err := a.analyze(g.arg.root)
+		if err != nil {
+			return nil, err
+		}
+		return a.shuffled(g.arg.root, info), nil
+	case *requireGenerator:
+		info, err := a.analyze(g.root)
+		if err != nil {
+			return nil, err
+		}
+		for i, required := range a.rules.require {
+			for _, chars := range g.requirements {
+				if isSubset(chars, required) {
+					info.has[i] = true
+				}
+			}
+		}
+		return info, nil
+	case *distinctGenerator:
+		return a.noRepeat(g.root)
+	case *noAdjacentGenerator:
+		return a.noRepeat(g.root)
+	case *functionCallGenerator:
+		info, err := a.analyze(g.gen)
+		if errors.Is(err, errPolicyUnsupported) {
+			return nil, fmt.Errorf("policy can not be checked for function $%s", g.funcName)
+		}
+		return info, err
+	}
+	return nil, errPolicyUnsupported
+}
+
+// noRepeat is the analysis of root with no identical adjacent characters
+func (a *policyAnalyzer) noRepeat(root *RootGenerator) (*patternInfo, error) {
+	info, err := a.analyze(root)
+	if err != nil {
+		return nil, err
+	}
+	if info.runs == nil {
+		return info, nil
+	}
+	info.runs.maxRun = min(info.runs.maxRun, 1)
+	for _, runs := range []map[rune]int64{info.runs.prefix, info.runs.suffix, info.runs.full} {
+		for c := range runs {
+			runs[c] = min(runs[c], 1)
+		}
+	}
+	return info, nil
+}
+
+func isSubset(chars map[rune]bool, other map[rune]bool) bool {
+	for c := range chars {
+		if !other[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// formatChars returns the sorted and quoted list of characters, with at
+// most 20 characters
+func formatChars(chars []rune) string {
+	slices.Sort(chars)
+	str := strconv.Quote(string(chars[:min(len(chars), 20)]))
+	if len(chars) > 20 {
+		str += fmt.Sprintf(" and %d more", len(chars)-20)
+	}
+	return str
+}
+
+func (a *policyAnalyzer) violations(info *patternInfo) []error {
+	policy := a.rules.policy
+	errs := []error{}
+	if info.minLen < int64(policy.MinLength) {
+		errs = append(errs, newPolicyViolation(
+			PolicyRuleMinLength,
+			"pattern can generate passwords with %d characters, minimum is %d",
+			info.minLen, policy.MinLength,
+		))
+	}
+	if policy.MaxLength > 0 && info.maxLen > int64(policy.MaxLength) {
+		errs = append(errs, newPolicyViolation(
+			PolicyRuleMaxLength,
+			"pattern can generate passwords with %d characters, maximum is %d",
+			info.maxLen, policy.MaxLength,
+		))
+	}
+	notInCharset := []rune{}
+	forbidden := []rune{}
+	for c := range info.chars {
+		if a.rules.forbidden[c] {
+			forbidden = append(forbidden, c)
+		}
+		if a.rules.charset != nil && !a.rules.charset[c] {
+			notInCharset = append(notInCharset, c)
+		}
+	}
+	if len(notInCharset) > 0 {
+		errs = append(errs, newPolicyViolation(
+			PolicyRuleCharset,
+			"pattern can generate characters that are not in %s: %s",
+			policy.Charset, formatChars(notInCharset),
+		))
+	}
+	for i, has := range info.has {
+		if !has {
+			errs = append(errs, newPolicyViolation(
+				PolicyRuleRequire,
+				"pattern can generate passwords without any character of %s",
+				policy.Require[i],
+			))
+		}
+	}
+	if len(forbidden) > 0 {
+		errs = append(errs, newPolicyViolation(
+			PolicyRuleForbidden,
+			"pattern can generate forbidden characters %s",
+			formatChars(forbidden),
+		))
+	}
+	if info.runs != nil && info.runs.maxRun > int64(policy.MaxRepeat) {
+		errs = append(errs, newPolicyViolation(
+			PolicyRuleMaxRepeat,
+			"pattern can generate more than %d identical consecutive characters",
+			policy.MaxRepeat,
+		))
+	}
+	return errs
+}
@@ -0,0 +1,196 @@
+package passgen_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+var testPolicy = &passgen.Policy{
+	MinLength: 12,
+	MaxLength: 20,
+	Require:   []string{"[:upper:]", "[:lower:]", "[:digit:]"},
+	Forbidden: "<>&",
+	MaxRepeat: 2,
+}
+
+func policyViolationRules(err error) []string {
+	rules := []string{}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return rules
+	}
+	for _, err := range joined.Unwrap() {
+		var violation *passgen.PolicyViolation
+		if errors.As(err, &violation) {
+			rules = append(rules, violation.Rule)
+		}
+	}
+	return rules
+}
+
+func TestCheckPolicy(t *testing.T) {
+	is := is.New(t)
+	test := func(policy *passgen.Policy, pattern string, rules ...string) {
+		is := is.AddMsg("pattern=%#v", pattern)
+		p, err := passgen.Compile([]rune(pattern))
+		is.NotErr(err)
+		err = p.CheckPolicy(policy)
+		if len(rules) == 0 {
+			is.NotErr(err)
+			return
+		}
+		is.Equal(policyViolationRules(err), rules)
+	}
+	test(testPolicy, `[:upper:][:lower:]{2}[:digit:]{2}-[:upper:][:lower:]{2}[:digit:]{2}-[:upper:]`)
+	test(testPolicy, `$require([:alnum:]{16},[:upper:],[:lower:],[:digit:])`, "max_repeat")
+	test(testPolicy, `[:alnum:]{16}`, "require", "require", "require", "max_repeat")
+	test(testPolicy, `[A-Z][a-z][0-9]{1,20}`, "min_length", "max_length", "max_repeat")
+	test(testPolicy, `([A-Z][a-z][0-9]&){4}`, "forbidden")
+	test(testPolicy, `([A-Z][a-z][0-9]){4}aaa`, "max_repeat")
+	test(testPolicy, `([A-Z][a-z][0-9]){4}aa`)
+	test(testPolicy, `([A-Z][a-z][0-9]){4}aa(a|b)`, "max_repeat")
+	test(testPolicy, `([A-Z][a-z][0-9]){4}a(a|b)`)
+	test(testPolicy, `([A-Z][a-z][0-9]){4}(a|[0-9])\2`, "max_repeat")
+	test(testPolicy, `(Aa0|Bb1)x$?(y)[0-9]{2}zzyy`, "min_length")
+	test(testPolicy, `Aa_$shuffle(xyz[0-9]{2}a)bcd`)
+	test(testPolicy, `Aa_$shuffle(xyz[0-9]{2}aaa)bcd`, "max_repeat")
+	test(&passgen.Policy{Charset: "[a-z0-9]"}, `[:alnum:]{8}`, "charset")
+	test(&passgen.Policy{Charset: "[a-z0-9]"}, `[:lower:]{8}[:digit:]`)
+	test(&passgen.Policy{MaxRepeat: 1}, `[:digit:]{8}`, "max_repeat")
+	test(&passgen.Policy{MaxRepeat: 1}, `([a-c][0-9]){8}`)
+
+	p, err := passgen.Compile([]rune(`$hex([a-z]{12})`))
+	is.NotErr(err)
+	is.ErrMsg(p.CheckPolicy(testPolicy), "policy can not be checked for function $hex")
+}
+
+func TestCheckPolicyMessages(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`[a-z&]{4,24}`))
+	is.NotErr(err)
+	is.ErrMsg(p.CheckPolicy(testPolicy), `policy min_length: pattern can generate passwords with 4 characters, minimum is 12
+policy max_length: pattern can generate passwords with 24 characters, maximum is 20
+policy require: pattern can generate passwords without any character of [:upper:]
+policy require: pattern can generate passwords without any character of [:lower:]
+policy require: pattern can generate passwords without any character of [:digit:]
+policy forbidden: pattern can generate forbidden characters "&"
+policy max_repeat: pattern can generate more than 2 identical consecutive characters`)
+}
+
+func TestPolicyCheckPassword(t *testing.T) {
+	is := is.New(t)
+	test := func(password string, errMsg string) {
+		err := testPolicy.CheckPassword([]rune(password))
+		is := is.AddMsg("password=%#v", password)
+		if errMsg == "" {
+			is.NotErr(err)
+			return
+		}
+		is.ErrMsg(err, errMsg)
+	}
+	test("Abcdef123456", "")
+	test("Abc123", "policy min_length: password has 6 characters, minimum is 12")
+	test("Abcdef123456Abcdef123456", "policy max_length: password has 24 characters, maximum is 20")
+	test("Abcdef123456&", `policy forbidden: password has forbidden character '&'`)
+	test("abcdef123456", "policy require: password has no character of [:upper:]")
+	test("Abcdef111456", "policy max_repeat: password has 3 identical consecutive characters, maximum is 2")
+
+	policy := &passgen.Policy{Charset: "[a-z]"}
+	is.ErrMsg(policy.CheckPassword([]rune("abC")), `policy charset: password has character 'C' which is not in [a-z]`)
+}
+
+func TestPolicyInvalid(t *testing.T) {
+	is := is.New(t)
+	test := func(policy *passgen.Policy, errMsg string) {
+		_, err := policy.Pattern()
+		is.ErrMsg(err, errMsg)
+	}
+	test(&passgen.Policy{MinLength: 10, MaxLength: 8}, "policy max_length: must not be less than min_length")
+	test(&passgen.Policy{MaxRepeat: -1}, "policy max_repeat: must not be negative")
+	test(&passgen.Policy{Require: []string{"abc"}}, `policy require: "abc" is not a character class`)
+	test(&passgen.Policy{Charset: "[:x:]"}, `policy charset: "[:x:]": value error near index 3: invalid character class "x"`)
+	test(&passgen.Policy{Charset: "[abc]", Forbidden: "cba"}, "policy forbidden: all characters are forbidden")
+}
+
+func TestPolicyPattern(t *testing.T) {
+	is := is.New(t)
+	pattern, err := (&passgen.Policy{
+		MinLength: 8,
+		MaxLength: 10,
+		Charset:   "[a-f\\-]",
+		Forbidden: "e",
+		Require:   []string{"[a-c]", "[\\-]"},
+	}).Pattern()
+	is.NotErr(err)
+	is.Equal(pattern, `$require([abcdf\-]{10},[a-c],[\-])`)
+
+	pattern, err = (&passgen.Policy{Forbidden: "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"}).Pattern()
+	is.NotErr(err)
+	is.Equal(pattern, `[0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz]{16}`)
+
+	pattern, err = testPolicy.Pattern()
+	is.NotErr(err)
+	p, err := passgen.Compile([]rune(pattern))
+	is.NotErr(err)
+	for range 100 {
+		out, err := p.GeneratePolicy(testPolicy)
+		is.NotErr(err)
+		is.NotErr(testPolicy.CheckPassword(out.Password))
+		is.Equal(len(out.Password), 16)
+	}
+}
+
+func TestGeneratePolicyEntropy(t *testing.T) {
+	is := is.New(t)
+	test := func(policy *passgen.Policy, entropy float64) {
+		pattern, err := policy.Pattern()
+		is.NotErr(err)
+		is := is.AddMsg("pattern=%#v", pattern)
+		p, err := passgen.Compile([]rune(pattern))
+		is.NotErr(err)
+		out, err := p.GeneratePolicy(policy)
+		is.NotErr(err)
+		is.True(math.Abs(out.PatternEntropy-entropy) < 0.0001)
+	}
+	test(&passgen.Policy{MaxLength: 4, Charset: "[ab]"}, 4)
+	// only "abab" and "baba" have no identical adjacent characters
+	test(&passgen.Policy{MaxLength: 4, Charset: "[ab]", MaxRepeat: 1}, 1)
+	test(&passgen.Policy{MaxLength: 4, Charset: "[ab]", MaxRepeat: 2}, math.Log2(10))
+	// 3*2*2*2 outputs have no identical adjacent characters, except "bcbc" and "cbcb"
+	test(&passgen.Policy{MaxLength: 4, Charset: "[abc]", Require: []string{"[a]"}, MaxRepeat: 1}, math.Log2(22))
+	test(&passgen.Policy{MaxLength: 4, Charset: "[abc]", Require: []string{"[a]", "[b]"}, MaxRepeat: 1}, math.Log2(20))
+}
+
+func TestExplainPolicyEntropy(t *testing.T) {
+	is := is.New(t)
+	for _, policy := range []*passgen.Policy{
+		{MaxLength: 4, Charset: "[ab]"},
+		{MaxLength: 4, Charset: "[ab]", MaxRepeat: 1},
+		{MaxLength: 4, Charset: "[abc]", Require: []string{"[a]", "[b]"}, MaxRepeat: 1},
+		testPolicy,
+	} {
+		pattern, err := policy.Pattern()
+		is.NotErr(err)
+		is := is.AddMsg("pattern=%#v", pattern)
+		p, err := passgen.Compile([]rune(pattern))
+		is.NotErr(err)
+		out, err := p.GeneratePolicy(policy)
+		is.NotErr(err)
+		node, err := p.ExplainPolicyEntropy(policy)
+		is.NotErr(err)
+		is.True(math.Abs(node.Entropy-out.PatternEntropy) < 0.0001)
+		if policy.MaxRepeat > 0 {
+			is.Equal(passgen.EntropyKindPolicy, node.Kind)
+			is.Equal(1, len(node.Children))
+			is.Equal(p.Entropy(), node.Children[0].Entropy)
+		}
+	}
+	p, err := passgen.Compile([]rune(`a{4}`))
+	is.NotErr(err)
+	_, err = p.ExplainPolicyEntropy(&passgen.Policy{MaxRepeat: 1})
+	is.ErrMsg(err, "policy max_repeat: pattern can never comply with max_repeat")
+}
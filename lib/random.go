@@ -3,22 +3,97 @@ package passgen
 import (
 	"crypto/rand"
 	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
 	math_rand "math/rand/v2"
 )
 
 // CryptoRandSource is a source for math/rand that uses more secure crypto/rand
-type CryptoRandSource struct{}
+type CryptoRandSource struct {
+	// Reader is the source of random bytes, crypto/rand.Reader is used if nil
+	Reader io.Reader
+}
 
 // NewRandSource creates a new source for math/rand that uses more secure crypto/rand
 func NewRandSource() *math_rand.Rand {
 	return math_rand.New(CryptoRandSource{})
 }
 
-func (CryptoRandSource) Uint64() uint64 {
+func (src CryptoRandSource) Uint64() uint64 {
+	reader := src.Reader
+	if reader == nil {
+		reader = rand.Reader
+	}
 	var b [8]byte
-	_, err := rand.Read(b[:])
+	_, err := io.ReadFull(reader, b[:])
 	if err != nil {
 		panic(err)
 	}
 	return binary.LittleEndian.Uint64(b[:])
 }
+
+// randInt returns a uniform random number in [0, n) from the random source of state
+func (s *State) randInt(n int64) (int64, error) {
+	ibig, err := rand.Int(s.rand, big.NewInt(n))
+	if err != nil {
+		return 0, fmt.Errorf("error in reading random data: %w", err)
+	}
+	return ibig.Int64(), nil
+}
+
+// randBigInt returns a uniform random number in [0, n) from the random source of state
+func (s *State) randBigInt(n *big.Int) (*big.Int, error) {
+	ibig, err := rand.Int(s.rand, n)
+	if err != nil {
+		return nil, fmt.Errorf("error in reading random data: %w", err)
+	}
+	return ibig, nil
+}
+
+// randFloat returns a uniform random number in [0, 1) from the random source of state
+func (s *State) randFloat() (float64, error) {
+	i, err := s.randInt(1 << 53)
+	if err != nil {
+		return 0, err
+	}
+	return float64(i) / (1 << 53), nil
+}
+
+// randWeighted returns a random index of given entropy list, where the
+// probability of index i is proportional to 2^entropyList[i]
+func (s *State) randWeighted(entropyList []float64) (int, error) {
+	maxEntropy := entropyList[0]
+	for _, entropy := range entropyList[1:] {
+		maxEntropy = max(maxEntropy, entropy)
+	}
+	weights := make([]float64, len(entropyList))
+	total := 0.0
+	for i, entropy := range entropyList {
+		weights[i] = math.Exp2(entropy - maxEntropy)
+		total += weights[i]
+	}
+	f, err := s.randFloat()
+	if err != nil {
+		return 0, err
+	}
+	f *= total
+	for i, weight := range weights {
+		if f < weight {
+			return i, nil
+		}
+		f -= weight
+	}
+	return len(weights) - 1, nil
+}
+
+// RandInt returns a uniform random number in [0, n) from the random
+// source of state, n must be positive
+// it can be used by custom generators, see RegisterFunction
+func (s *State) RandInt(n int64) (int64, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid argument to RandInt: %v", n)
+	}
+	return s.randInt(n)
+}
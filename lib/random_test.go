@@ -0,0 +1,120 @@
+package passgen_test
+
+import (
+	"errors"
+	"io"
+	math_rand "math/rand/v2"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+const allRandomPattern = `[:alnum:]{8,12}(ab|cd|ef)$byte()$date(2000,2020)$bip39word(2)$shuffle([a-z]{5}[0-9]{3})$?(x)`
+
+func seededRand(seed byte) io.Reader {
+	var s [32]byte
+	s[0] = seed
+	return math_rand.NewChaCha8(s)
+}
+
+func TestGenerateRandReproducible(t *testing.T) {
+	is := is.New(t)
+	gen := func(seed byte) string {
+		out, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern: []rune(allRandomPattern),
+			Rand:    seededRand(seed),
+		})
+		is.NotErr(err)
+		return string(out.Password)
+	}
+	is.Equal(gen(1), gen(1))
+	is.Equal(gen(2), gen(2))
+	is.True(gen(1) != gen(2))
+}
+
+func TestPatternWithRand(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(allRandomPattern))
+	is.NotErr(err)
+	p1 := p.WithRand(seededRand(1))
+	p2 := p.WithRand(seededRand(1))
+	for range 5 {
+		out1, err := p1.Generate()
+		is.NotErr(err)
+		out2, err := p2.Generate()
+		is.NotErr(err)
+		is.Equal(string(out1.Password), string(out2.Password))
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestGenerateRandError(t *testing.T) {
+	is := is.New(t)
+	for _, pattern := range []string{
+		`[a-z]`,
+		`a{1,2}`,
+		`(a|b)`,
+		`$byte()`,
+		`$date(2000,2020)`,
+		`$bip39word(1)`,
+		`$shuffle(abc)`,
+		`$?(x)`,
+	} {
+		is := is.AddMsg("pattern=%#v", pattern)
+		out, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern: []rune(pattern),
+			Rand:    errReader{},
+		})
+		is.Nil(out)
+		is.ErrMsg(err, "error in reading random data: unexpected EOF")
+		is.True(errors.Is(err, io.ErrUnexpectedEOF))
+	}
+}
+
+func TestGenerateUniform(t *testing.T) {
+	is := is.New(t)
+	countMatches := func(pattern string, uniform bool, match func(string) bool) int {
+		p, err := passgen.CompileInput(passgen.GenerateInput{
+			Pattern: []rune(pattern),
+			Rand:    seededRand(1),
+			Uniform: uniform,
+		})
+		is.NotErr(err)
+		count := 0
+		for range 3000 {
+			out, err := p.Generate()
+			is.NotErr(err)
+			if match(string(out.Password)) {
+				count++
+			}
+		}
+		return count
+	}
+	isShort := func(pw string) bool {
+		return len(pw) == 1
+	}
+	isA := func(pw string) bool {
+		return pw == "a"
+	}
+	// 2 of 6 possible passwords have length 1
+	n := countMatches(`[ab]{1,2}`, true, isShort)
+	is.True(n > 850 && n < 1150)
+	n = countMatches(`[ab]{1,2}`, false, isShort)
+	is.True(n > 1350 && n < 1650)
+	// 1 of 11 possible passwords is "a"
+	n = countMatches(`(a|[0-9])`, true, isA)
+	is.True(n > 180 && n < 370)
+	n = countMatches(`(a|[0-9])`, false, isA)
+	is.True(n > 1350 && n < 1650)
+	// length 12 is only 1/51 of all passwords, so it must be rare
+	n = countMatches(`[:alpha:]{12,16}`, true, func(pw string) bool {
+		return len(pw) < 16
+	})
+	is.True(n > 0 && n < 120)
+}
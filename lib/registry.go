@@ -0,0 +1,247 @@
+package passgen
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// FunctionSpec is the specification of a custom function that can be
+// called in pattern as $name(...), exactly one of Encoder or Generator
+// must be set
+type FunctionSpec struct {
+	// Encoder is a text function, like $hex(...) or $base64(...)
+	// argument of function is parsed as a pattern, and the generated
+	// string is passed to Encoder
+	// entropy of function call is the entropy of argument
+	Encoder func(in []rune) ([]rune, error)
+
+	// Generator creates a generator from the raw argument of function,
+	// which is not parsed as a pattern, like $date(2000,2020)
+	// the generator must calculate its own entropy
+	// see State.AppendOutput and State.RandInt for implementing Generate
+	Generator func(arg []rune) (GeneratorIface, error)
+}
+
+var (
+	customFunctionsMutex sync.RWMutex
+	customFunctions      = map[string]*FunctionSpec{}
+)
+
+// RegisterFunction registers a custom function with given name and spec
+// so that it can be called in patterns as $name(...)
+// name must consist of letters, digits and underscore, and must not be
+// the name of a built-in or already registered function
+// it is safe to call RegisterFunction concurrently with generating passwords
+func RegisterFunction(name string, spec FunctionSpec) error {
+	if name == "" {
+		return errors.New("function name is empty")
+	}
+	for _, c := range name {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' {
+			return fmt.Errorf("invalid character %q in function name %#v", c, name)
+		}
+	}
+	if (spec.Encoder == nil) == (spec.Generator == nil) {
+		return fmt.Errorf("function %#v: exactly one of Encoder or Generator must be set", name)
+	}
+	customFunctionsMutex.Lock()
+	defer customFunctionsMutex.Unlock()
+	if isBuiltinFunction(name) || customFunctions[name] != nil {
+		return fmt.Errorf("function %#v is already registered", name)
+	}
+	customFunctions[name] = &spec
+	return nil
+}
+
+// FunctionNames returns the sorted list of names of all functions,
+// including built-in and registered functions
+func FunctionNames() []string {
+	names := make([]string, 0, len(encoderFunctions)+len(generatorFunctions))
+	for name := range encoderFunctions {
+		names = append(names, name)
+	}
+	for name := range generatorFunctions {
+		names = append(names, name)
+	}
+	customFunctionsMutex.RLock()
+	for name := range customFunctions {
+		names = append(names, name)
+	}
+	customFunctionsMutex.RUnlock()
+	slices.SortFunc(names, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	return names
+}
+
+// unregisterFunction removes the registered custom function with given name
+func unregisterFunction(name string) {
+	customFunctionsMutex.Lock()
+	defer customFunctionsMutex.Unlock()
+	delete(customFunctions, name)
+}
+
+func isBuiltinFunction(name string) bool {
+	if _, ok := encoderFunctions[name]; ok {
+		return true
+	}
+	_, ok := generatorFunctions[name]
+	return ok
+}
+
+func getCustomFunction(name string) *FunctionSpec {
+	customFunctionsMutex.RLock()
+	defer customFunctionsMutex.RUnlock()
+	return customFunctions[name]
+}
+
+// getEncoderFunction returns the built-in or custom encoder function
+// with given name, or nil if there is no such encoder
+func getEncoderFunction(name string) func(s *State, in []rune) ([]rune, error) {
+	if encoder, ok := encoderFunctions[name]; ok {
+		return encoder
+	}
+	spec := getCustomFunction(name)
+	if spec == nil || spec.Encoder == nil {
+		return nil
+	}
+	return func(s *State, in []rune) ([]rune, error) {
+		out, err := spec.Encoder(in)
+		if err != nil {
+			return nil, customFunctionError(s, err)
+		}
+		return out, nil
+	}
+}
+
+// customFunctionError converts error of a custom function to *Error
+// to be reported with the position of function call
+func customFunctionError(s *State, err error) error {
+	var myErr *Error
+	if errors.As(err, &myErr) {
+		return err
+	}
+	return s.errorValue("%v", err.Error())
+}
+
+var (
+	customCharClassesMutex sync.RWMutex
+	customCharClasses      = map[string][]rune{}
+)
+
+// RegisterCharClass registers a named character class, so that it can be
+// used in patterns as [:name:], or combined with other classes like
+// [:name::punct:]
+// name must not contain ':' or ']', and must not be the name of a
+// built-in or already registered class, see OverrideCharClass
+func RegisterCharClass(name string, chars []rune) error {
+	return registerCharClass(name, chars, false)
+}
+
+// OverrideCharClass is like RegisterCharClass, but replaces the built-in
+// or registered class with the same name, if there is any
+func OverrideCharClass(name string, chars []rune) error {
+	return registerCharClass(name, chars, true)
+}
+
+func registerCharClass(name string, chars []rune, override bool) error {
+	err := checkCharClass(name, chars)
+	if err != nil {
+		return err
+	}
+	customCharClassesMutex.Lock()
+	defer customCharClassesMutex.Unlock()
+	if !override {
+		_, builtin := charClasses[name]
+		if builtin || customCharClasses[name] != nil {
+			return fmt.Errorf("character class %#v is already registered", name)
+		}
+	}
+	customCharClasses[name] = slices.Clone(chars)
+	return nil
+}
+
+func checkCharClass(name string, chars []rune) error {
+	if name == "" {
+		return errors.New("character class name is empty")
+	}
+	if strings.ContainsAny(name, ":]") {
+		return fmt.Errorf("invalid character class name %#v", name)
+	}
+	if len(chars) == 0 {
+		return fmt.Errorf("character class %#v is empty", name)
+	}
+	return nil
+}
+
+// checkCharClasses checks the character classes of GenerateInput, and
+// returns a copy of them
+func checkCharClasses(classes map[string][]rune) (map[string][]rune, error) {
+	if len(classes) == 0 {
+		return nil, nil
+	}
+	result := make(map[string][]rune, len(classes))
+	for name, chars := range classes {
+		err := checkCharClass(name, chars)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := getCharClass(name); ok {
+			return nil, fmt.Errorf("character class %#v is already registered", name)
+		}
+		result[name] = slices.Clone(chars)
+	}
+	return result, nil
+}
+
+// unregisterCharClass removes the registered character class with given name
+func unregisterCharClass(name string) {
+	customCharClassesMutex.Lock()
+	defer customCharClassesMutex.Unlock()
+	delete(customCharClasses, name)
+}
+
+// CharClassNames returns the sorted list of names of all named
+// character classes, including built-in and registered classes
+func CharClassNames() []string {
+	names := make([]string, 0, len(charClasses))
+	for name := range charClasses {
+		names = append(names, name)
+	}
+	customCharClassesMutex.RLock()
+	for name := range customCharClasses {
+		if _, builtin := charClasses[name]; !builtin {
+			names = append(names, name)
+		}
+	}
+	customCharClassesMutex.RUnlock()
+	slices.Sort(names)
+	return names
+}
+
+// getCharClass returns the characters of named class, classes of
+// GenerateInput take precedence over registered and built-in classes
+func (s *State) getCharClass(name string) ([]rune, bool) {
+	chars, ok := s.charClasses[name]
+	if ok {
+		return chars, true
+	}
+	return getCharClass(name)
+}
+
+// getCharClass returns the characters of named class, registered
+// classes take precedence over built-in classes
+func getCharClass(name string) ([]rune, bool) {
+	customCharClassesMutex.RLock()
+	chars, ok := customCharClasses[name]
+	customCharClassesMutex.RUnlock()
+	if ok {
+		return chars, true
+	}
+	chars, ok = charClasses[name]
+	return chars, ok
+}
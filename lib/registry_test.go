@@ -0,0 +1,198 @@
+package passgen_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+type testPrefixGenerator struct {
+	prefixes []string
+}
+
+func (g *testPrefixGenerator) Generate(s *passgen.State) error {
+	i, err := s.RandInt(int64(len(g.prefixes)))
+	if err != nil {
+		return err
+	}
+	s.AppendOutput([]rune(g.prefixes[i]))
+	return nil
+}
+
+func (g *testPrefixGenerator) Entropy(_ *passgen.State) (float64, error) {
+	return 1, nil
+}
+
+func TestRegisterFunction(t *testing.T) {
+	is := is.New(t)
+	t.Cleanup(func() {
+		passgen.UnregisterFunction("test_reverse")
+		passgen.UnregisterFunction("test_prefix")
+	})
+	err := passgen.RegisterFunction("test_reverse", passgen.FunctionSpec{
+		Encoder: func(in []rune) ([]rune, error) {
+			if len(in) == 0 {
+				return nil, errors.New("empty input")
+			}
+			out := slices.Clone(in)
+			slices.Reverse(out)
+			return out, nil
+		},
+	})
+	is.NotErr(err)
+	err = passgen.RegisterFunction("test_prefix", passgen.FunctionSpec{
+		Generator: func(arg []rune) (passgen.GeneratorIface, error) {
+			prefixes := strings.Split(string(arg), ",")
+			if len(prefixes) != 2 {
+				return nil, errors.New("need 2 prefixes")
+			}
+			return &testPrefixGenerator{prefixes: prefixes}, nil
+		},
+	})
+	is.NotErr(err)
+
+	{
+		p, err := passgen.Compile([]rune(`$test_reverse(abc[0-9]{2})`))
+		is.NotErr(err)
+		isFloatBetween(is, p.Entropy(), 6.64, 6.65)
+		out, err := p.Generate()
+		is.NotErr(err)
+		is.Equal("cba", string(out.Password[2:]))
+	}
+	{
+		p, err := passgen.Compile([]rune(`$test_prefix(ab,cd)-[a-z]`))
+		is.NotErr(err)
+		isFloatBetween(is, p.Entropy(), 5.70, 5.71)
+		out, err := p.Generate()
+		is.NotErr(err)
+		pw := string(out.Password)
+		is.True(strings.HasPrefix(pw, "ab-") || strings.HasPrefix(pw, "cd-"))
+	}
+	{
+		_, err := passgen.Compile([]rune(`x$test_prefix(ab)`))
+		is.ErrMsg(err, "value error near index 15: need 2 prefixes")
+	}
+	{
+		_, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern: []rune(`x$test_reverse()`),
+		})
+		is.ErrMsg(err, "value error near index 14: empty input")
+	}
+
+	names := passgen.FunctionNames()
+	is.True(slices.Contains(names, "test_reverse"))
+	is.True(slices.Contains(names, "test_prefix"))
+	is.True(slices.Contains(names, "base64"))
+	is.True(slices.Contains(names, "shuffle"))
+}
+
+func TestRegisterFunctionError(t *testing.T) {
+	is := is.New(t)
+	t.Cleanup(func() {
+		passgen.UnregisterFunction("test_dup")
+	})
+	encoder := func(in []rune) ([]rune, error) {
+		return in, nil
+	}
+	is.ErrMsg(
+		passgen.RegisterFunction("", passgen.FunctionSpec{Encoder: encoder}),
+		"function name is empty",
+	)
+	is.ErrMsg(
+		passgen.RegisterFunction("a(b", passgen.FunctionSpec{Encoder: encoder}),
+		`invalid character '(' in function name "a(b"`,
+	)
+	is.ErrMsg(
+		passgen.RegisterFunction("test_none", passgen.FunctionSpec{}),
+		`function "test_none": exactly one of Encoder or Generator must be set`,
+	)
+	is.ErrMsg(
+		passgen.RegisterFunction("hex", passgen.FunctionSpec{Encoder: encoder}),
+		`function "hex" is already registered`,
+	)
+	is.ErrMsg(
+		passgen.RegisterFunction("date", passgen.FunctionSpec{Encoder: encoder}),
+		`function "date" is already registered`,
+	)
+	is.NotErr(passgen.RegisterFunction("test_dup", passgen.FunctionSpec{Encoder: encoder}))
+	is.ErrMsg(
+		passgen.RegisterFunction("test_dup", passgen.FunctionSpec{Encoder: encoder}),
+		`function "test_dup" is already registered`,
+	)
+}
+
+func TestRegisterCharClass(t *testing.T) {
+	is := is.New(t)
+	t.Cleanup(func() {
+		passgen.UnregisterCharClass("test_safe")
+	})
+	is.NotErr(passgen.RegisterCharClass("test_safe", []rune("abcdefghjkmnpqrstuvwxyz23456789")))
+	{
+		p, err := passgen.Compile([]rune(`[:test_safe:]{4}`))
+		is.NotErr(err)
+		isFloatBetween(is, p.Entropy(), 19.8, 19.9)
+		out, err := p.Generate()
+		is.NotErr(err)
+		is.Equal("", strings.Trim(string(out.Password), "abcdefghjkmnpqrstuvwxyz23456789"))
+	}
+	{
+		// 31 + 32 characters
+		p, err := passgen.Compile([]rune(`[:test_safe::punct:]`))
+		is.NotErr(err)
+		isFloatBetween(is, p.Entropy(), 5.97, 5.98)
+	}
+	is.ErrMsg(
+		passgen.RegisterCharClass("test_safe", []rune("abc")),
+		`character class "test_safe" is already registered`,
+	)
+	is.ErrMsg(
+		passgen.RegisterCharClass("digit", []rune("abc")),
+		`character class "digit" is already registered`,
+	)
+	is.ErrMsg(passgen.RegisterCharClass("", []rune("abc")), "character class name is empty")
+	is.ErrMsg(passgen.RegisterCharClass("a:b", []rune("abc")), `invalid character class name "a:b"`)
+	is.ErrMsg(passgen.RegisterCharClass("test_empty", nil), `character class "test_empty" is empty`)
+
+	is.NotErr(passgen.OverrideCharClass("test_safe", []rune("ab")))
+	{
+		p, err := passgen.Compile([]rune(`[:test_safe:]{4}`))
+		is.NotErr(err)
+		is.Equal(4.0, p.Entropy())
+	}
+	names := passgen.CharClassNames()
+	is.True(slices.Contains(names, "test_safe"))
+	is.True(slices.Contains(names, "alnum"))
+}
+
+func TestInputCharClasses(t *testing.T) {
+	is := is.New(t)
+	classes := map[string][]rune{"test_input": []rune("xyz")}
+	p, err := passgen.CompileInput(passgen.GenerateInput{
+		Pattern:     []rune(`[:test_input:]{4}$hex([:test_input:])`),
+		CharClasses: classes,
+	})
+	is.NotErr(err)
+	isFloatBetween(is, p.Entropy(), 7.92, 7.93)
+	out, err := p.Generate()
+	is.NotErr(err)
+	is.Equal("", strings.Trim(string(out.Password[:4]), "xyz"))
+	is.False(slices.Contains(passgen.CharClassNames(), "test_input"))
+
+	_, err = passgen.Compile([]rune(`[:test_input:]`))
+	is.ErrMsg(err, `value error near index 12: invalid character class "test_input"`)
+
+	test := func(classes map[string][]rune, errMsg string) {
+		_, err := passgen.CompileInput(passgen.GenerateInput{
+			Pattern:     []rune(`a`),
+			CharClasses: classes,
+		})
+		is.ErrMsg(err, errMsg)
+	}
+	test(map[string][]rune{"digit": []rune("abc")}, `character class "digit" is already registered`)
+	test(map[string][]rune{"a:b": []rune("abc")}, `invalid character class name "a:b"`)
+	test(map[string][]rune{"test_empty": nil}, `character class "test_empty" is empty`)
+}
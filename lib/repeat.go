@@ -1,29 +1,164 @@
 package passgen
 
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
 type repeatGenerator struct {
-	child GeneratorIface
-	count int64
+	child    GeneratorIface
+	minCount int64
+	maxCount int64
+}
+
+func (g *repeatGenerator) count(s *State) (int64, error) {
+	if g.maxCount == g.minCount {
+		return g.minCount, nil
+	}
+	if s.uniform {
+		return g.uniformCount(s)
+	}
+	i, err := s.randInt(g.maxCount - g.minCount + 1)
+	if err != nil {
+		return 0, err
+	}
+	return g.minCount + i, nil
+}
+
+// uniformCount returns a random count where the probability of count n is
+// proportional to the keyspace size of repeating child n times: 2^(H*n)
+// it uses the inverse of the CDF of truncated geometric distribution of
+// k = maxCount - n, that has probability proportional to 2^(-H*k)
+func (g *repeatGenerator) uniformCount(s *State) (int64, error) {
+	childEntropy, err := g.child.Entropy(s)
+	if err != nil {
+		return 0, err
+	}
+	if childEntropy <= 0 {
+		i, err := s.randInt(g.maxCount - g.minCount + 1)
+		if err != nil {
+			return 0, err
+		}
+		return g.minCount + i, nil
+	}
+	f, err := s.randFloat()
+	if err != nil {
+		return 0, err
+	}
+	m := g.maxCount - g.minCount
+	// 1 - 2^(-H*(m+1)), total probability before normalization
+	total := -math.Expm1(-childEntropy * float64(m+1) * math.Ln2)
+	k := int64(math.Log1p(-f*total) / (-childEntropy * math.Ln2))
+	return g.maxCount - min(max(k, 0), m), nil
 }
 
 func (g *repeatGenerator) Generate(s *State) error {
 	child := g.child
-	count := g.count
+	count, err := g.count(s)
+	if err != nil {
+		return err
+	}
 	for range count {
 		err := child.Generate(s)
-		if s.tooLong() {
-			break
+		if err != nil {
+			return err
 		}
+		err = s.checkLimits()
 		if err != nil {
 			return err
 		}
+		if s.tooLong() {
+			break
+		}
 	}
 	return nil
 }
 
+// Entropy returns the entropy of repeating child
+// for range repetition {M,N}, it's log2 of the total keyspace summed over
+// all counts from M to N if s.uniform is true, and the minimum entropy
+// otherwise, see repeatMinEntropy
+// both do not depend on the generated password
 func (g *repeatGenerator) Entropy(s *State) (float64, error) {
 	childEntropy, err := g.child.Entropy(s)
 	if err != nil {
 		return 0, err
 	}
-	return childEntropy * float64(g.count), nil
+	if s.uniform {
+		return repeatEntropy(childEntropy, g.minCount, g.maxCount), nil
+	}
+	return repeatMinEntropy(childEntropy, g.minCount, g.maxCount), nil
+}
+
+// repeatMinEntropy returns log2(maxCount-minCount+1) + childEntropy*minCount
+// which is the min-entropy when counts are chosen with equal probability,
+// because the most likely passwords are the ones with minimum count
+func repeatMinEntropy(childEntropy float64, minCount int64, maxCount int64) float64 {
+	if maxCount <= minCount {
+		return childEntropy * float64(minCount)
+	}
+	return math.Log2(float64(maxCount-minCount+1)) + childEntropy*float64(minCount)
+}
+
+// repeatEntropy returns log2(sum(2^(childEntropy*n) for n in [minCount, maxCount]))
+// which is calculated as:
+// childEntropy*maxCount + log2(sum(2^(-childEntropy*k) for k in [0, maxCount-minCount]))
+// and the second term (geometric series) is calculated in closed form
+// to avoid overflow for large values
+func repeatEntropy(childEntropy float64, minCount int64, maxCount int64) float64 {
+	if maxCount <= minCount {
+		return childEntropy * float64(minCount)
+	}
+	terms := float64(maxCount - minCount + 1)
+	if childEntropy <= 0 {
+		return math.Log2(terms)
+	}
+	// 1 - 2^(-x) == -expm1(-x*ln2), more precise for small x
+	oneMinus := func(x float64) float64 {
+		return -math.Expm1(-x * math.Ln2)
+	}
+	series := math.Log2(oneMinus(childEntropy*terms)) - math.Log2(oneMinus(childEntropy))
+	return childEntropy*float64(maxCount) + series
+}
+
+func (g *repeatGenerator) countPattern() string {
+	if g.minCount == 0 && g.maxCount == 1 {
+		return "?"
+	}
+	if g.minCount == g.maxCount {
+		return "{" + strconv.FormatInt(g.minCount, 10) + "}"
+	}
+	return fmt.Sprintf("{%d,%d}", g.minCount, g.maxCount)
+}
+
+func (g *repeatGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	childNode, err := explainGenerator(s, g.child)
+	if err != nil {
+		return nil, err
+	}
+	detail := fmt.Sprintf("%d times", g.minCount)
+	entropy := repeatMinEntropy(childNode.Entropy, g.minCount, g.maxCount)
+	if g.maxCount != g.minCount {
+		keyspace := repeatEntropy(childNode.Entropy, g.minCount, g.maxCount)
+		detail = fmt.Sprintf(
+			"%d to %d times, log2(%d) + minimum count, keyspace of all counts is %.2f bits",
+			g.minCount, g.maxCount, g.maxCount-g.minCount+1, keyspace,
+		)
+		if s.uniform {
+			detail = fmt.Sprintf(
+				"%d to %d times, keyspace of all counts, minimum entropy is %.2f bits",
+				g.minCount, g.maxCount, entropy,
+			)
+			entropy = keyspace
+		}
+	}
+	return &EntropyNode{
+		Kind:     EntropyKindRepeat,
+		Pattern:  childNode.Pattern + g.countPattern(),
+		Detail:   detail,
+		Count:    g.minCount,
+		Entropy:  entropy,
+		Children: []*EntropyNode{childNode},
+	}, nil
 }
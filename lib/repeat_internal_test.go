@@ -2,6 +2,7 @@ package passgen
 
 import (
 	"fmt"
+	"math"
 	"strings"
 	"testing"
 
@@ -30,8 +31,9 @@ func Test_parseRepeatCount(t *testing.T) {
 		pattern := fmt.Sprintf("%s{%s}", pre, count)
 		s := newTestState(pattern)
 		s.move(uint64(len(pattern)))
-		c, err := parseRepeatCount(s, []rune(count))
-		is.Equal(0, c)
+		minCount, maxCount, err := parseRepeatCount(s, []rune(count))
+		is.Equal(0, minCount)
+		is.Equal(0, maxCount)
 		return err.(*Error)
 	}
 
@@ -65,3 +67,27 @@ func Test_parseRepeatCount(t *testing.T) {
 		)
 	}
 }
+
+func Test_repeatEntropy(t *testing.T) {
+	is := is.New(t)
+	naive := func(childEntropy float64, minCount int64, maxCount int64) float64 {
+		sum := 0.0
+		for n := minCount; n <= maxCount; n++ {
+			sum += math.Pow(2, childEntropy*float64(n))
+		}
+		return math.Log2(sum)
+	}
+	isClose := func(expected float64, actual float64) {
+		if math.Abs(expected-actual) > 1e-9 {
+			t.Errorf("expected %v, got %v", expected, actual)
+		}
+	}
+	is.Equal(20.0, repeatEntropy(5, 4, 4))
+	is.Equal(2.0, repeatEntropy(0, 1, 4))
+	isClose(naive(math.Log2(26), 8, 10), repeatEntropy(math.Log2(26), 8, 10))
+	isClose(naive(math.Log2(52), 12, 16), repeatEntropy(math.Log2(52), 12, 16))
+	isClose(naive(1, 0, 5), repeatEntropy(1, 0, 5))
+	isClose(naive(0.001, 3, 100), repeatEntropy(0.001, 3, 100))
+	// must not overflow for large counts
+	isClose(6*1000+math.Log2(64.0/63), repeatEntropy(6, 0, 1000))
+}
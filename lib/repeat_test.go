@@ -29,7 +29,7 @@ func TestRepeatGeneratorByGroup(t *testing.T) {
 	{
 		entropy, err := g.Entropy(s)
 		is.NotErr(err)
-		isFloatBetween(is, entropy, 25.3, 25.4)
+		isFloatBetween(is, entropy, 6.33, 6.34)
 	}
 }
 
@@ -69,3 +69,68 @@ func TestRepeatGeneratorGroupFuncError(t *testing.T) {
 		tErr.SpacedError(),
 	)
 }
+
+func TestOptionalRepeat(t *testing.T) {
+	is := is.New(t)
+	test := func(pattern string, uniform bool, minEntropy float64, maxEntropy float64, lengths ...int) {
+		is := is.AddMsg("pattern=%#v, uniform=%v", pattern, uniform)
+		p, err := passgen.CompileInput(passgen.GenerateInput{
+			Pattern: []rune(pattern),
+			Uniform: uniform,
+		})
+		is.NotErr(err)
+		isFloatBetween(is, p.Entropy(), minEntropy, maxEntropy)
+		seen := map[int]bool{}
+		for range 200 {
+			out, err := p.Generate()
+			is.NotErr(err)
+			seen[len(out.Password)] = true
+		}
+		expected := map[int]bool{}
+		for _, length := range lengths {
+			expected[length] = true
+		}
+		if uniform {
+			// short lengths may be too unlikely to be seen
+			for length := range seen {
+				is.AddMsg("length=%d", length).True(expected[length])
+			}
+			return
+		}
+		is.Equal(expected, seen)
+	}
+	// min-entropy: log2(3), because P("") = 1/3
+	test(`[a-z]{,2}`, false, 1.58, 1.59, 0, 1, 2)
+	test(`[a-z]{0,2}`, false, 1.58, 1.59, 0, 1, 2)
+	// log2(1 + 26 + 26^2)
+	test(`[a-z]{,2}`, true, 9.45, 9.46, 0, 1, 2)
+	test(`[a-z]{0,2}`, true, 9.45, 9.46, 0, 1, 2)
+	// min-entropy: log2(2), because P("ac") = 1/2
+	test(`a[a-z]?c`, false, 1, 1, 2, 3)
+	// log2(1 + 26)
+	test(`a[a-z]?c`, true, 4.75, 4.76, 2, 3)
+	test(`(ab)?`, false, 1, 1, 0, 2)
+	test(`(ab)?`, true, 1, 1, 0, 2)
+	test(`(?:a|b)?`, false, 1, 1, 0, 1)
+	test(`(?:a|b)?`, true, 1.58, 1.59, 0, 1)
+	test(`$hex(a)?`, false, 1, 1, 0, 2)
+	test(`[a-z]{2}?`, false, 1, 1, 0, 2)
+	test(`[a-z]{2}?`, true, 9.40, 9.41, 0, 2)
+
+	_, err := passgen.Compile([]rune(`?a`))
+	is.ErrMsg(err, "syntax error near index 0: nothing to repeat")
+	_, err = passgen.Compile([]rune(`a{0,0}`))
+	is.ErrMsg(err, "syntax error near index 4: invalid natural number '0'")
+	p, err := passgen.Compile([]rune(`[a-z]?`))
+	is.NotErr(err)
+	node, err := p.ExplainEntropy()
+	is.NotErr(err)
+	is.Equal(`[a-z]?`, node.Children[0].Pattern)
+	// empty password is generated half of the times, so min-entropy is 1 bit
+	is.Equal(1.0, node.Entropy)
+	is.Equal(1.0, p.Entropy())
+	is.Equal(
+		"0 to 1 times, log2(2) + minimum count, keyspace of all counts is 4.75 bits",
+		node.Children[0].Detail,
+	)
+}
@@ -0,0 +1,363 @@
+package passgen
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+const (
+	// requireMaxAttempts is the maximum number of times that argument of
+	// $require is generated until all requirements are satisfied
+	requireMaxAttempts = 10000
+
+	// requireMinProbability is the minimum probability of satisfying all
+	// requirements of $require, lower probabilities are rejected in compile
+	// phase, so that generating never fails in practice
+	requireMinProbability = 0.01
+)
+
+// requireGenerator is the generator of $require(PATTERN, CLASS1, CLASS2, ...)
+// that generates PATTERN until it has at least one character of each CLASS
+// (rejection sampling), so the output is unbiased
+type requireGenerator struct {
+	root         *RootGenerator
+	argPattern   []rune
+	reqPatterns  [][]rune
+	requirements []map[rune]bool
+
+	// probability is the probability that generated argument satisfies
+	// all requirements
+	probability float64
+}
+
+func newRequireGenerator(s *State, argsStr []rune) (*requireGenerator, error) {
+	args, _, err := splitArgsStr(argsStr, ',')
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 2 {
+		s.errorOffset += int64(len(argsStr) + 1)
+		return nil, s.errorArg("require: at least 2 arguments are required")
+	}
+	return &requireGenerator{
+		argPattern:  args[0],
+		reqPatterns: args[1:],
+	}, nil
+}
+
+// trimSpaceRunes returns the number of leading and trailing spaces of arg
+func trimSpaceRunes(arg []rune) (int, int) {
+	lead := 0
+	for lead < len(arg) && arg[lead] == ' ' {
+		lead++
+	}
+	trail := 0
+	for trail < len(arg)-lead && arg[len(arg)-1-trail] == ' ' {
+		trail++
+	}
+	return lead, trail
+}
+
+func (g *requireGenerator) compileRequirement(s *State, arg []rune) (map[rune]bool, error) {
+	// the comma before argument
+	s.errorOffset++
+	lead, trail := trimSpaceRunes(arg)
+	s.errorOffset += int64(lead)
+	pattern := arg[lead : len(arg)-trail]
+	root, err := subCompile(s, pattern)
+	if err != nil {
+		return nil, err
+	}
+	chars := singleCharClass(root)
+	if len(chars) == 0 {
+		s.errorMarkLen = max(len(pattern), 1)
+		return nil, s.errorValue("require: %#v is not a character class", string(pattern))
+	}
+	s.errorOffset += int64(trail)
+	return runeSet(chars), nil
+}
+
+// singleCharClass returns the characters of root, if it is a single
+// character class or a single character, otherwise returns nil
+func singleCharClass(root *RootGenerator) []rune {
+	if len(root.children) != 1 {
+		return nil
+	}
+	switch gen := root.children[0].(type) {
+	case *charClassGenerator:
+		if len(gen.charClasses) == 1 {
+			return gen.charClasses[0]
+		}
+	case *staticStringGenerator:
+		return gen.str
+	}
+	return nil
+}
+
+func (g *requireGenerator) compile(s *State) error {
+	root, err := subCompile(s, g.argPattern)
+	if err != nil {
+		return err
+	}
+	requirements := make([]map[rune]bool, len(g.reqPatterns))
+	for i, arg := range g.reqPatterns {
+		requirements[i], err = g.compileRequirement(s, arg)
+		if err != nil {
+			return err
+		}
+	}
+	// errors of probability are marked on first argument
+	s.errorOffset -= int64(len(g.reqPatterns))
+	for _, arg := range g.reqPatterns {
+		s.errorOffset -= int64(len(arg))
+	}
+	s.errorMarkLen = max(len(g.argPattern), 1)
+	probability, err := requireProbability(s, root, requirements)
+	if err != nil {
+		return err
+	}
+	if probability < requireMinProbability {
+		if probability <= 0 {
+			return s.errorValue("require: requirements can never be satisfied")
+		}
+		return s.errorValue("require: requirements are satisfied with probability %.2g, which is too low", probability)
+	}
+	g.root = root
+	g.requirements = requirements
+	g.probability = probability
+	return nil
+}
+
+func (g *requireGenerator) satisfied(output []rune) bool {
+	for _, chars := range g.requirements {
+		found := false
+		for _, c := range output {
+			if chars[c] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *requireGenerator) Generate(s *State) error {
+	if g.root == nil {
+		err := g.compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	for range requireMaxAttempts {
+		argState := NewState(s.SharedState, nil)
+		err := g.root.Generate(argState)
+		if err != nil {
+			return err
+		}
+		if g.satisfied(argState.output) {
+			s.addOutput(argState.output)
+			return nil
+		}
+	}
+	return fmt.Errorf("require: requirements are not satisfied after %d attempts", requireMaxAttempts)
+}
+
+// Entropy returns the entropy of argument plus log2 of probability that
+// generated argument satisfies all requirements, which is exact if all
+// passwords matching the argument are equally likely (see Uniform)
+func (g *requireGenerator) Entropy(s *State) (float64, error) {
+	if g.root == nil {
+		return 0, s.errorUnknown(s_entropy_not_calc)
+	}
+	entropy, err := g.root.Entropy(s)
+	if err != nil {
+		return 0, err
+	}
+	return entropy + math.Log2(g.probability), nil
+}
+
+func (g *requireGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	if g.root == nil {
+		return nil, s.errorUnknown(s_entropy_not_calc)
+	}
+	argNode, err := g.root.explainEntropy(s)
+	if err != nil {
+		return nil, err
+	}
+	return &EntropyNode{
+		Detail: fmt.Sprintf(
+			"entropy of argument + log2(%.4g), probability of having all %d required classes",
+			g.probability,
+			len(g.requirements),
+		),
+		Entropy:  argNode.Entropy + math.Log2(g.probability),
+		Children: []*EntropyNode{argNode},
+	}, nil
+}
+
+// requireProbability returns the probability that output of root has at
+// least one character of each set of requirements, using inclusion-exclusion
+// over subsets T of requirements: sum of (-1)^|T| * P(output avoids union of T)
+func requireProbability(s *State, root *RootGenerator, requirements []map[rune]bool) (float64, error) {
+	if len(requirements) > 16 {
+		return 0, s.errorValue("require: too many requirements, maximum is 16")
+	}
+	probability := 0.0
+	for mask := uint(0); mask < 1<<len(requirements); mask++ {
+		excluded := []map[rune]bool{}
+		for i, chars := range requirements {
+			if mask&(1<<i) != 0 {
+				excluded = append(excluded, chars)
+			}
+		}
+		p, err := avoidProbability(s, root, excluded)
+		if err != nil {
+			return 0, err
+		}
+		if bits.OnesCount(mask)%2 == 1 {
+			p = -p
+		}
+		probability += p
+	}
+	return min(max(probability, 0), 1), nil
+}
+
+// avoidProbability returns the probability that output of gen does not
+// have any character of excluded sets
+func avoidProbability(s *State, gen GeneratorIface, excluded []map[rune]bool) (float64, error) {
+	if len(excluded) == 0 {
+		return 1, nil
+	}
+	isExcluded := func(c rune) bool {
+		for _, chars := range excluded {
+			if chars[c] {
+				return true
+			}
+		}
+		return false
+	}
+	switch g := gen.(type) {
+	case *RootGenerator:
+		p := 1.0
+		for _, child := range g.children {
+			childP, err := avoidProbability(s, child, excluded)
+			if err != nil {
+				return 0, err
+			}
+			p *= childP
+		}
+		return p, nil
+	case *staticStringGenerator:
+		for _, c := range g.str {
+			if isExcluded(c) {
+				return 0, nil
+			}
+		}
+		return 1, nil
+	case *charClassGenerator:
+		p := 1.0
+		for _, chars := range g.charClasses {
+			if len(chars) == 0 {
+				continue
+			}
+			count := 0
+			for _, c := range chars {
+				if !isExcluded(c) {
+					count++
+				}
+			}
+			p *= float64(count) / float64(len(chars))
+		}
+		return p, nil
+	case *groupGenerator:
+		return avoidProbability(s, g.root, excluded)
+	case *alterGenerator:
+		return alterAvoidProbability(s, g, excluded)
+	case *repeatGenerator:
+		return repeatAvoidProbability(s, g, excluded)
+	case *functionCallGenerator:
+		if wg, ok := g.gen.(*weightedGenerator); ok {
+			return weightedAvoidProbability(s, wg, excluded)
+		}
+	}
+	return 0, s.errorValue("require: argument must only have characters, character classes, groups, alterations and repetitions")
+}
+
+func alterAvoidProbability(s *State, g *alterGenerator, excluded []map[rune]bool) (float64, error) {
+	weights := make([]float64, len(g.roots))
+	if s.uniform {
+		maxEntropy := math.Inf(-1)
+		for _, entropy := range g.entropyList {
+			maxEntropy = max(maxEntropy, entropy)
+		}
+		for i, entropy := range g.entropyList {
+			weights[i] = math.Exp2(entropy - maxEntropy)
+		}
+	} else {
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	total := 0.0
+	p := 0.0
+	for i, root := range g.roots {
+		branchP, err := avoidProbability(s, root, excluded)
+		if err != nil {
+			return 0, err
+		}
+		p += weights[i] * branchP
+		total += weights[i]
+	}
+	return p / total, nil
+}
+
+func weightedAvoidProbability(s *State, g *weightedGenerator, excluded []map[rune]bool) (float64, error) {
+	p := 0.0
+	for i, root := range g.roots {
+		branchP, err := avoidProbability(s, root, excluded)
+		if err != nil {
+			return 0, err
+		}
+		p += g.probList[i] * branchP
+	}
+	return p, nil
+}
+
+func repeatAvoidProbability(s *State, g *repeatGenerator, excluded []map[rune]bool) (float64, error) {
+	childP, err := avoidProbability(s, g.child, excluded)
+	if err != nil {
+		return 0, err
+	}
+	minCount, maxCount := g.minCount, g.maxCount
+	if minCount == maxCount || childP == 1 {
+		return math.Pow(childP, float64(minCount)), nil
+	}
+	terms := float64(maxCount - minCount + 1)
+	if !s.uniform {
+		// mean of childP^n for n in [minCount, maxCount]
+		if childP == 0 {
+			if minCount == 0 {
+				return 1 / terms, nil
+			}
+			return 0, nil
+		}
+		series := -math.Expm1(terms*math.Log(childP)) / (1 - childP)
+		return math.Pow(childP, float64(minCount)) * series / terms, nil
+	}
+	childEntropy, err := g.child.Entropy(s)
+	if err != nil {
+		return 0, err
+	}
+	total := repeatEntropy(childEntropy, minCount, maxCount)
+	if childP == 0 {
+		if minCount == 0 {
+			return math.Exp2(-total), nil
+		}
+		return 0, nil
+	}
+	return math.Exp2(repeatEntropy(childEntropy+math.Log2(childP), minCount, maxCount) - total), nil
+}
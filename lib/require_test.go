@@ -0,0 +1,116 @@
+package passgen_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestRequire(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`$require([:graph:]{16}, [:upper:], [:lower:], [:digit:], [:punct:])`))
+	is.NotErr(err)
+	for range 100 {
+		out, err := p.Generate()
+		is.NotErr(err)
+		pw := string(out.Password)
+		is.Equal(len(pw), 16)
+		is.AddMsg("password=%#v", pw).True(
+			strings.ContainsAny(pw, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") &&
+				strings.ContainsAny(pw, "abcdefghijklmnopqrstuvwxyz") &&
+				strings.ContainsAny(pw, "0123456789") &&
+				strings.ContainsAny(pw, "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"),
+		)
+	}
+	// 94 = 26 + 26 + 10 + 32
+	expected := 0.0
+	for mask := range 16 {
+		size := 94.0
+		sign := 1.0
+		for i, classSize := range []float64{26, 26, 10, 32} {
+			if mask&(1<<i) != 0 {
+				size -= classSize
+				sign = -sign
+			}
+		}
+		expected += sign * math.Pow(size, 16)
+	}
+	is.True(math.Abs(p.Entropy()-math.Log2(expected)) < 1e-6)
+	is.True(p.Entropy() < 16*math.Log2(94))
+}
+
+func TestRequireEntropy(t *testing.T) {
+	is := is.New(t)
+	entropy := func(pattern string, uniform bool) float64 {
+		p, err := passgen.CompileInput(passgen.GenerateInput{
+			Pattern: []rune(pattern),
+			Uniform: uniform,
+		})
+		is.AddMsg("pattern=%#v", pattern).NotErr(err)
+		return p.Entropy()
+	}
+	test := func(pattern string, uniform bool, expected float64) {
+		actual := entropy(pattern, uniform)
+		if math.Abs(expected-actual) > 1e-9 {
+			t.Errorf("pattern=%#v: expected %v, got %v", pattern, expected, actual)
+		}
+	}
+	// 4 of 8 passwords are "aab", "aba", "abb", "baa", ... having both a and b
+	test(`$require([ab]{3},a,b)`, false, math.Log2(6))
+	test(`$require([ab]{3},[a],[b])`, false, math.Log2(6))
+	// all passwords have digit
+	test(`$require(x[0-9]{4}, [:digit:])`, false, entropy(`x[0-9]{4}`, false))
+	// 2 + 4 + 8 = 14 passwords, 2 of them without a or without b
+	// per length: 0 of 2, 2 of 4, 6 of 8 (uniform)
+	test(`$require([ab]{1,3},a,b)`, true, math.Log2(8))
+	// non-uniform: (0 + 1/2 + 6/8) / 3 = 5/12
+	test(`$require([ab]{1,3},a,b)`, false, entropy(`[ab]{1,3}`, false)+math.Log2(5.0/12))
+	// alteration: (1/2 * 1/2) + (1/2 * 0)
+	test(`$require((a[0-9]|[:upper:]),[a-z])`, false, entropy(`(a[0-9]|[:upper:])`, false)-1)
+}
+
+func TestRequireError(t *testing.T) {
+	testGenErr(t, &genErrCase{
+		Pattern: `$require([a-z]{8})`,
+		Error:   `                 ^ argument error: require: at least 2 arguments are required`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$require([a-z]{8}, abc)`,
+		Error:   `                   ^^^ value error: require: "abc" is not a character class`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$require([a-z]{8}, [:x:])`,
+		Error:   `                    ^^^ value error: invalid character class "x"`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$require([a-z]{8}, [0-9])`,
+		Error:   `         ^^^^^^^^ value error: require: requirements can never be satisfied`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$require([a-z]{3}, x, y)`,
+		Error:   `         ^^^^^^^^ value error: require: requirements are satisfied with probability 0.0085, which is too low`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$require($byte(), [0-9])`,
+		Error:   `         ^^^^^^^ value error: require: argument must only have characters, character classes, groups, alterations and repetitions`,
+	})
+}
+
+func TestRequireExplain(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`$require([ab]{3},a,b)`))
+	is.NotErr(err)
+	node, err := p.ExplainEntropy()
+	is.NotErr(err)
+	is.Equal(p.Entropy(), node.Entropy)
+	is.Equal(1, len(node.Children))
+	function := node.Children[0]
+	is.Equal(passgen.EntropyKindFunction, function.Kind)
+	is.Equal(`$require([ab]{3},a,b)`, function.Pattern)
+	is.Equal("entropy of argument + log2(0.75), probability of having all 2 required classes", function.Detail)
+	is.Equal(1, len(function.Children))
+	is.Equal(3.0, function.Children[0].Entropy)
+}
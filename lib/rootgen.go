@@ -6,17 +6,47 @@ func NewRootGenerator() *RootGenerator {
 }
 
 // RootGenerator is the root Generator implementation
+// it holds the sequence of generators compiled from a pattern
 type RootGenerator struct {
-	entropy *float64
+	children []GeneratorIface
+	pattern  []rune
+	compiled bool
 }
 
-// Generate generates a password
-func (g *RootGenerator) Generate(s *State) error {
+// compile parses the pattern of given state into a sequence of generators
+func (g *RootGenerator) compile(s *State) error {
 	err := g.lexLoop(s)
 	if err != nil {
 		return err
 	}
-	g.entropy = &s.patternEntropy
+	g.children = s.nodes
+	g.pattern = s.input
+	g.compiled = true
+	return nil
+}
+
+// Generate generates a password
+// pattern of given state is compiled if it's not already compiled
+func (g *RootGenerator) Generate(s *State) error {
+	if !g.compiled {
+		err := g.compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	for _, child := range g.children {
+		err := child.Generate(s)
+		if err != nil {
+			return err
+		}
+		err = s.checkLimits()
+		if err != nil {
+			return err
+		}
+		if s.tooLong() {
+			break
+		}
+	}
 	return nil
 }
 
@@ -34,10 +64,48 @@ func (g *RootGenerator) lexLoop(s *State) error {
 	}
 }
 
-// Entropy returns the entropy after .Generate() is called
+// Entropy returns the entropy after pattern is compiled
 func (g *RootGenerator) Entropy(s *State) (float64, error) {
-	if g.entropy != nil {
-		return *g.entropy, nil
+	if !g.compiled {
+		return 0, s.errorUnknown(s_entropy_not_calc)
+	}
+	entropy := 0.0
+	for _, child := range g.children {
+		childEntropy, err := child.Entropy(s)
+		if err != nil {
+			return 0, err
+		}
+		entropy += childEntropy
+	}
+	return entropy, nil
+}
+
+func (g *RootGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	if !g.compiled {
+		return nil, s.errorUnknown(s_entropy_not_calc)
+	}
+	node := &EntropyNode{
+		Kind:    EntropyKindSequence,
+		Pattern: string(g.pattern),
+	}
+	// consecutive static characters are shown as one literal
+	var literal *EntropyNode
+	for _, child := range g.children {
+		if static, ok := child.(*staticStringGenerator); ok {
+			if literal == nil {
+				literal = &EntropyNode{Kind: EntropyKindLiteral}
+				node.Children = append(node.Children, literal)
+			}
+			literal.Pattern += string(static.str)
+			continue
+		}
+		literal = nil
+		childNode, err := explainGenerator(s, child)
+		if err != nil {
+			return nil, err
+		}
+		node.Entropy += childNode.Entropy
+		node.Children = append(node.Children, childNode)
 	}
-	return 0, s.errorUnknown(s_entropy_not_calc)
+	return node, nil
 }
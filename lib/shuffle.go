@@ -1,38 +1,155 @@
 package passgen
 
-func shuffle(_ *State, in []rune) ([]rune, error) {
-	r := NewRandSource()
-	r.Shuffle(len(in), func(i int, j int) {
+import (
+	"math"
+	"slices"
+)
+
+// shuffle does Fisher-Yates shuffle using the random source of state
+func shuffle(s *State, in []rune) ([]rune, error) {
+	for i := len(in) - 1; i > 0; i-- {
+		j, err := s.randInt(int64(i + 1))
+		if err != nil {
+			return nil, err
+		}
 		in[i], in[j] = in[j], in[i]
-	})
+	}
 	return in, nil
 }
 
 type shuffleGenerator struct {
-	argPatternEntropy *float64
-	argPattern        []rune
+	arg        *functionArg
+	argPattern []rune
 }
 
-func (g *shuffleGenerator) Generate(s *State) error {
-	argState := NewState(s.SharedState, g.argPattern)
-	err := baseFunctionCallGenerator(
-		s,
-		argState,
-		shuffle,
-	)
+func (g *shuffleGenerator) compile(s *State) error {
+	arg, err := compileFunctionArg(s, g.argPattern)
 	if err != nil {
 		return err
 	}
-	g.argPatternEntropy = &s.patternEntropy
+	g.arg = arg
 	return nil
 }
 
+func (g *shuffleGenerator) Generate(s *State) error {
+	if g.arg == nil {
+		err := g.compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	return baseFunctionCallGenerator(
+		s,
+		g.arg,
+		shuffle,
+	)
+}
+
+// Entropy returns the entropy of shuffled argument
+// see shuffleEntropy
 func (g *shuffleGenerator) Entropy(s *State) (float64, error) {
-	// FIXME: how to calculate entropy?
-	if g.argPatternEntropy != nil {
-		return *g.argPatternEntropy, nil
+	if g.arg == nil {
+		return 0, s.errorUnknown(s_entropy_not_calc)
 	}
-	return 0, s.errorUnknown(s_entropy_not_calc)
+	entropy, _, err := shuffleEntropy(s, g.arg.root)
+	return entropy, err
+}
+
+// shuffleSlot is a set of characters, and the number of characters
+// in argument of $shuffle that are chosen from it
+type shuffleSlot struct {
+	chars []rune
+	count int64
+}
+
+// shuffleSlots returns the list of slots of argument, if argument is
+// a sequence of static strings, char classes and fixed repetitions of them
+// otherwise returns false
+func shuffleSlots(root *RootGenerator) ([]*shuffleSlot, bool) {
+	slotByKey := map[string]*shuffleSlot{}
+	slots := []*shuffleSlot{}
+	add := func(chars []rune, count int64) {
+		sorted := slices.Clone(chars)
+		slices.Sort(sorted)
+		key := string(sorted)
+		slot := slotByKey[key]
+		if slot == nil {
+			slot = &shuffleSlot{chars: chars}
+			slotByKey[key] = slot
+			slots = append(slots, slot)
+		}
+		slot.count += count
+	}
+	var collect func(gen GeneratorIface, count int64) bool
+	collect = func(gen GeneratorIface, count int64) bool {
+		switch g := gen.(type) {
+		case *staticStringGenerator:
+			for _, c := range g.str {
+				add([]rune{c}, count)
+			}
+			return true
+		case *charClassGenerator:
+			for _, chars := range g.charClasses {
+				if len(chars) > 0 {
+					add(chars, count)
+				}
+			}
+			return true
+		case *repeatGenerator:
+			if g.minCount != g.maxCount {
+				return false
+			}
+			return collect(g.child, count*g.minCount)
+		}
+		return false
+	}
+	for _, child := range root.children {
+		if !collect(child, 1) {
+			return nil, false
+		}
+	}
+	return slots, true
+}
+
+// shuffleEntropy returns the entropy of shuffled output of root
+//
+// if argument is a sequence of characters chosen from sets that are
+// either identical or disjoint, like $shuffle([:upper:][:lower:]{3}[:digit:]),
+// the exact entropy is calculated as entropy of argument plus log2 of
+// multinomial count of distinct orderings of sets: n! / (c_1! * ... * c_k!)
+//
+// otherwise, entropy of argument is returned, which is a lower bound,
+// because shuffling never decreases entropy, and exact is false
+func shuffleEntropy(s *State, root *RootGenerator) (entropy float64, exact bool, err error) {
+	entropy, err = root.Entropy(s)
+	if err != nil {
+		return 0, false, err
+	}
+	slots, ok := shuffleSlots(root)
+	if !ok {
+		return entropy, false, nil
+	}
+	slotByChar := map[rune]*shuffleSlot{}
+	for _, slot := range slots {
+		for _, c := range slot.chars {
+			other, found := slotByChar[c]
+			if found && other != slot {
+				return entropy, false, nil
+			}
+			slotByChar[c] = slot
+		}
+	}
+	// log2(n! / (c_1! * ... * c_k!)) using log-gamma: ln(n!) = lgamma(n+1)
+	total := int64(0)
+	orderings := 0.0
+	for _, slot := range slots {
+		total += slot.count
+		lgamma, _ := math.Lgamma(float64(slot.count + 1))
+		orderings -= lgamma
+	}
+	lgamma, _ := math.Lgamma(float64(total + 1))
+	orderings += lgamma
+	return entropy + orderings/math.Ln2, true, nil
 }
 
 func newShuffleGenerator(arg []rune) (*shuffleGenerator, error) {
@@ -40,3 +157,23 @@ func newShuffleGenerator(arg []rune) (*shuffleGenerator, error) {
 		argPattern: arg,
 	}, nil
 }
+
+func (g *shuffleGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	if g.arg == nil {
+		return nil, s.errorUnknown(s_entropy_not_calc)
+	}
+	node, err := explainArgEntropy(s, g.arg.root)
+	if err != nil {
+		return nil, err
+	}
+	entropy, exact, err := shuffleEntropy(s, g.arg.root)
+	if err != nil {
+		return nil, err
+	}
+	node.Entropy = entropy
+	node.Detail = "lower bound: entropy of argument"
+	if exact {
+		node.Detail = "entropy of argument + log2 of number of distinct orderings"
+	}
+	return node, nil
+}
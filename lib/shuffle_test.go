@@ -2,6 +2,7 @@ package passgen_test
 
 import (
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/ilius/is/v2"
@@ -26,6 +27,32 @@ func TestShuffleGenerator(t *testing.T) {
 	{
 		entropy, err := g.Entropy(s)
 		is.NotErr(err)
-		isFloatBetween(is, entropy, 29.8, 29.9)
+		// log2(26^5 * 9^2) + log2(7! / (5! * 2!))
+		isFloatBetween(is, entropy, 34.23, 34.24)
 	}
 }
+
+func TestShuffleEntropy(t *testing.T) {
+	is := is.New(t)
+	entropy := func(pattern string) float64 {
+		p, err := passgen.Compile([]rune(pattern))
+		is.NotErr(err)
+		return p.Entropy()
+	}
+	test := func(pattern string, argPattern string, orderings float64) {
+		expected := entropy(argPattern) + math.Log2(orderings)
+		actual := entropy(pattern)
+		if math.Abs(expected-actual) > 1e-9 {
+			t.Errorf("pattern=%#v: expected %v, got %v", pattern, expected, actual)
+		}
+	}
+	test(`$shuffle([:upper:][:lower:][:digit:][:punct:])`, `[:upper:][:lower:][:digit:][:punct:]`, 24)
+	test(`$shuffle(ab[0-9])`, `ab[0-9]`, 6)
+	test(`$shuffle(aa[0-9])`, `aa[0-9]`, 3)
+	test(`$shuffle([:upper:]{2}[:lower:]{3}\d)`, `[:upper:]{2}[:lower:]{3}\d`, 60)
+	// same set, shuffling does not change anything
+	test(`$shuffle([a-z]{3})`, `[a-z]{3}`, 1)
+	// overlapping sets, entropy of argument is a lower bound
+	test(`$shuffle([a-z][a-c])`, `[a-z][a-c]`, 1)
+	test(`$shuffle([a-z]{2,3}[0-9])`, `[a-z]{2,3}[0-9]`, 1)
+}
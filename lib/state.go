@@ -1,23 +1,59 @@
 package passgen
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
+	"io"
 	"log"
-	"math"
-	"os"
-	"strconv"
 )
 
 // SharedState is the shared part of State
 type SharedState struct {
-	groupsOutput   map[uint64][]rune
-	absPos         uint64
-	errorOffset    int64
-	errorMarkLen   int
-	patternEntropy float64
-	lastGroupId    uint64
+	groupsOutput  map[uint64][]rune
+	definedGroups map[uint64]bool
+	absPos        uint64
+	errorOffset   int64
+	errorMarkLen  int
+	lastGroupId   uint64
+
+	// groupNames maps name of named groups (?<name>...) to group id
+	groupNames map[string]uint64
+
+	// rand is the source of random bytes used by generators
+	rand io.Reader
+
+	// depth is the current nesting depth in compile phase
+	depth int
+
+	// usedPatterns is the set of named patterns that are being compiled
+	// used to detect recursive $use(name)
+	usedPatterns map[string]bool
 
 	maxOutputLength int
+	truncateOutput  bool
+	maxRepeatCount  int64
+	maxDepth        int
+	ctx             context.Context
+
+	// uniform is true if counts of range repetitions and branches of
+	// alterations are chosen weighted by their keyspace size
+	uniform bool
+
+	// universe is the set of characters that negated character classes
+	// are complemented against, printable ASCII characters if nil
+	universe []rune
+
+	// exclude is the characters that are removed from character classes
+	exclude []rune
+
+	// charClasses is the named character classes of GenerateInput
+	charClasses map[string][]rune
+
+	// allowFiles is true if $wordfile and $line can read files
+	allowFiles bool
+	// fileDir is the directory that files are restricted to, if not empty
+	fileDir string
 }
 
 func (ss *SharedState) Copy() *SharedState {
@@ -34,12 +70,23 @@ type State struct {
 	buffer []rune
 	output []rune
 
+	// nodes is the list of generators that are compiled by lexer
+	nodes []GeneratorIface
+
 	inputPos uint64
 
 	openParenth uint64
 	openBracket bool
+	rangeStart  uint64
+	groupStart  uint64
+	// groupNoCapture is true inside non-capturing group (?:...)
+	groupNoCapture bool
 
 	rangeReverse bool
+
+	// classStack is the stack of outer character classes, while parsing
+	// a nested [...] inside a character class, like [[:alnum:]--[0O]]
+	classStack []classFrame
 }
 
 func (s *State) move(chars uint64) {
@@ -56,22 +103,23 @@ func (s *State) moveBack(chars uint64) {
 	s.absPos -= chars
 }
 
-func (s *State) addOutputOne(c rune) error {
-	if s.tooLong() {
-		s.lastGen = nil
-		return nil
-	}
-	s.lastGen = &staticStringGenerator{str: []rune{c}}
-	return s.lastGen.Generate(s)
+// addGen adds a compiled generator, which can be repeated by a following {...}
+func (s *State) addGen(gen GeneratorIface) {
+	s.nodes = append(s.nodes, gen)
+	s.lastGen = gen
 }
 
-func (s *State) addOutput(str []rune) error {
-	s.lastGen = &staticStringGenerator{str: str}
-	return s.lastGen.Generate(s)
+// replaceLastGen replaces the last compiled generator, used for repetition
+func (s *State) replaceLastGen(gen GeneratorIface) {
+	s.nodes[len(s.nodes)-1] = gen
+	s.lastGen = gen
 }
 
-func (s *State) addOutputNonRepeatable(data []rune) {
-	s.lastGen = nil
+func (s *State) addStatic(str []rune) {
+	s.addGen(&staticStringGenerator{str: str})
+}
+
+func (s *State) addOutput(data []rune) {
 	s.output = append(s.output, data...)
 }
 
@@ -79,13 +127,45 @@ func (s *State) tooLong() bool {
 	return s.maxOutputLength > 0 && len(s.output) > s.maxOutputLength
 }
 
-func (s *State) end() bool {
-	if s.tooLong() {
-		return true
+// checkLimits returns error if output is too long or context is done
+// if output is truncated, it returns nil and generators stop on tooLong
+func (s *State) checkLimits() error {
+	if s.tooLong() && !s.truncateOutput {
+		return fmt.Errorf("password is longer than %d characters", s.maxOutputLength)
+	}
+	if s.ctx != nil {
+		return s.ctx.Err()
 	}
+	return nil
+}
+
+func (s *State) end() bool {
 	return s.inputPos >= uint64(len(s.input))
 }
 
+// errorPos is the position info of State that is needed to report an error
+// in generate phase, after lexing is finished
+type errorPos struct {
+	absPos      uint64
+	errorOffset int64
+}
+
+func (s *State) errorPos() errorPos {
+	return errorPos{
+		absPos:      s.absPos,
+		errorOffset: s.errorOffset,
+	}
+}
+
+// withErrorPos returns a copy of State with the given error position
+func (s *State) withErrorPos(pos errorPos) *State {
+	ss := s.SharedState.Copy()
+	ss.absPos = pos.absPos
+	ss.errorOffset = pos.errorOffset
+	ss.errorMarkLen = 1
+	return NewState(ss, s.input)
+}
+
 func (s *State) getErrorPos() uint {
 	if s.absPos == 0 {
 		if s.errorOffset < 0 {
@@ -136,22 +216,15 @@ func (s *State) errorUnknown(msg string, args ...any) error {
 
 // NewSharedState is factory function for SharedState
 func NewSharedState() *SharedState {
-	ss := &SharedState{
-		groupsOutput: map[uint64][]rune{},
-		errorMarkLen: 1,
+	return &SharedState{
+		groupsOutput:   map[uint64][]rune{},
+		definedGroups:  map[uint64]bool{},
+		groupNames:     map[string]uint64{},
+		errorMarkLen:   1,
+		rand:           rand.Reader,
+		usedPatterns:   map[string]bool{},
+		maxRepeatCount: defaultMaxRepeatCount,
 	}
-	maxLengthStr := os.Getenv("REPASSGEN_MAX_LENGTH")
-	if maxLengthStr != "" {
-		maxLength, err := strconv.ParseInt(maxLengthStr, 10, 64)
-		if err != nil {
-			panic("invalid REPASSGEN_MAX_LENGTH: must be an integer")
-		}
-		if maxLength > math.MaxInt {
-			panic("REPASSGEN_MAX_LENGTH is larger than MaxInt")
-		}
-		ss.maxOutputLength = int(maxLength)
-	}
-	return ss
 }
 
 // NewState is factory function for State
@@ -162,3 +235,9 @@ func NewState(ss *SharedState, pattern []rune) *State {
 	}
 	return s
 }
+
+// AppendOutput appends data to the generated output
+// it can be used by custom generators, see RegisterFunction
+func (s *State) AppendOutput(data []rune) {
+	s.addOutput(data)
+}
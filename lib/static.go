@@ -12,3 +12,10 @@ func (g *staticStringGenerator) Generate(s *State) error {
 func (g *staticStringGenerator) Entropy(_ *State) (float64, error) {
 	return 0, nil
 }
+
+func (g *staticStringGenerator) explainEntropy(_ *State) (*EntropyNode, error) {
+	return &EntropyNode{
+		Kind:    EntropyKindLiteral,
+		Pattern: string(g.str),
+	}, nil
+}
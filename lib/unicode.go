@@ -0,0 +1,94 @@
+package passgen
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// getUnicodeTable returns the Unicode script or category table with given
+// name, like "Cyrillic", "Greek", "L" or "Lu"
+func getUnicodeTable(name string) (*unicode.RangeTable, bool) {
+	table, ok := unicode.Scripts[name]
+	if ok {
+		return table, true
+	}
+	table, ok = unicode.Categories[name]
+	return table, ok
+}
+
+// getUnicodeScript returns the Unicode script table with given name,
+// case-insensitive, like "cyrillic" or "Han"
+func getUnicodeScript(name string) (*unicode.RangeTable, bool) {
+	table, ok := unicode.Scripts[name]
+	if ok {
+		return table, true
+	}
+	for scriptName, table := range unicode.Scripts {
+		if strings.EqualFold(scriptName, name) {
+			return table, true
+		}
+	}
+	return nil, false
+}
+
+// getUnicodeClass returns the characters of a Unicode script or category
+// name can be "script=NAME" for case-insensitive script name
+// if scriptFold is true, script names are case-insensitive without prefix
+func getUnicodeClass(name string, scriptFold bool) ([]rune, bool) {
+	prefix, scriptName, ok := strings.Cut(name, "=")
+	if ok {
+		if !strings.EqualFold(prefix, "script") {
+			return nil, false
+		}
+		table, ok := getUnicodeScript(scriptName)
+		if !ok {
+			return nil, false
+		}
+		return unicodeTableChars(table), true
+	}
+	table, ok := getUnicodeTable(name)
+	if !ok && scriptFold {
+		table, ok = getUnicodeScript(name)
+	}
+	if !ok {
+		return nil, false
+	}
+	return unicodeTableChars(table), true
+}
+
+// unicodeTableChars returns the assigned and printable characters of table
+func unicodeTableChars(table *unicode.RangeTable) []rune {
+	chars := []rune{}
+	for _, r16 := range table.R16 {
+		for c := rune(r16.Lo); c <= rune(r16.Hi); c += rune(r16.Stride) {
+			if unicode.IsPrint(c) {
+				chars = append(chars, c)
+			}
+		}
+	}
+	for _, r32 := range table.R32 {
+		for c := rune(r32.Lo); c <= rune(r32.Hi); c += rune(r32.Stride) {
+			if unicode.IsPrint(c) {
+				chars = append(chars, c)
+			}
+		}
+	}
+	return chars
+}
+
+// Universe returns the characters of a named character class (like "alpha"
+// or a registered class), or of a Unicode script or category (like
+// "Cyrillic" or "Lu"), to be used as GenerateInput.Universe
+// only assigned and printable characters of Unicode tables are included
+func Universe(name string) ([]rune, error) {
+	chars, ok := getCharClass(name)
+	if ok {
+		return removeDuplicateRunes(chars), nil
+	}
+	chars, ok = getUnicodeClass(name, true)
+	if !ok {
+		return nil, fmt.Errorf("invalid universe %#v, must be a character class, Unicode script or category", name)
+	}
+	return chars, nil
+}
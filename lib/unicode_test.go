@@ -0,0 +1,136 @@
+package passgen_test
+
+import (
+	"math"
+	"testing"
+	"unicode"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestUniverse(t *testing.T) {
+	is := is.New(t)
+
+	chars, err := passgen.Universe("digit")
+	is.NotErr(err)
+	is.Equal(string(chars), "0123456789")
+
+	chars, err = passgen.Universe("Greek")
+	is.NotErr(err)
+	is.True(len(chars) > 100)
+	for _, c := range chars {
+		is.True(unicode.Is(unicode.Greek, c))
+		is.True(unicode.IsPrint(c))
+	}
+
+	chars, err = passgen.Universe("Lu")
+	is.NotErr(err)
+	is.True(len(chars) > 1000)
+
+	_, err = passgen.Universe("NoSuchScript")
+	is.ErrMsg(err, `invalid universe "NoSuchScript", must be a character class, Unicode script or category`)
+}
+
+func TestGenerateUniverse(t *testing.T) {
+	is := is.New(t)
+	universe, err := passgen.Universe("Cyrillic")
+	is.NotErr(err)
+	p, err := passgen.CompileInput(passgen.GenerateInput{
+		Pattern:  []rune(`[^абв]{10}`),
+		Universe: universe,
+	})
+	is.NotErr(err)
+	is.True(math.Abs(p.Entropy()-10*math.Log2(float64(len(universe)-3))) < 0.0001)
+	for range 20 {
+		out, err := p.Generate()
+		is.NotErr(err)
+		is.Equal(len(out.Password), 10)
+		for _, c := range out.Password {
+			is.True(unicode.Is(unicode.Cyrillic, c))
+			is.True(c != 'а' && c != 'б' && c != 'в')
+		}
+	}
+
+	// universe is also used in nested classes and function arguments
+	universe = []rune("abcdef")
+	for _, pattern := range []string{
+		`[^a-c]{8}`,
+		`[[^a-c]&&[a-e]]{8}`,
+		`$shuffle([^abc]{8})`,
+	} {
+		is := is.AddMsg("pattern=%#v", pattern)
+		out, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern:  []rune(pattern),
+			Universe: universe,
+		})
+		is.NotErr(err)
+		is.Equal(len(out.Password), 8)
+		for _, c := range out.Password {
+			is.True(c >= 'd' && c <= 'f')
+		}
+	}
+}
+
+func TestGenerateUnicodeProperty(t *testing.T) {
+	is := is.New(t)
+	greekCount := 0
+	for _, c := range unicode.Greek.R16 {
+		for r := rune(c.Lo); r <= rune(c.Hi); r += rune(c.Stride) {
+			if unicode.IsPrint(r) {
+				greekCount++
+			}
+		}
+	}
+	for _, c := range unicode.Greek.R32 {
+		for r := rune(c.Lo); r <= rune(c.Hi); r += rune(c.Stride) {
+			if unicode.IsPrint(r) {
+				greekCount++
+			}
+		}
+	}
+	// entropy is not checked if it's 0
+	test := func(pattern string, entropy float64, check func(rune) bool) {
+		is := is.AddMsg("pattern=%#v", pattern)
+		out, _, err := passgen.Generate(passgen.GenerateInput{
+			Pattern: []rune(pattern),
+		})
+		is.NotErr(err)
+		if entropy > 0 {
+			is.True(math.Abs(out.PatternEntropy-entropy) < 0.0001)
+		}
+		for _, c := range out.Password {
+			is.AddMsg("char=%#v", string(c)).True(check(c))
+		}
+	}
+	isGreek := func(c rune) bool {
+		return unicode.Is(unicode.Greek, c) && unicode.IsPrint(c)
+	}
+	greekEntropy := 8 * math.Log2(float64(greekCount))
+	test(`\p{Greek}{8}`, greekEntropy, isGreek)
+	test(`[\p{Greek}]{8}`, greekEntropy, isGreek)
+	test(`[:greek:]{8}`, greekEntropy, isGreek)
+	test(`[:script=Greek:]{8}`, greekEntropy, isGreek)
+	test(`[\p{Greek}--[\p{Lu}]]{8}`, 0, func(c rune) bool {
+		return isGreek(c) && !unicode.IsUpper(c)
+	})
+	test(`\p{Nd}{4}`, 0, unicode.IsDigit)
+	test(`\P{L}{8}`, 8*math.Log2(95-52), func(c rune) bool {
+		return c >= ' ' && c <= '~' && !unicode.IsLetter(c)
+	})
+	// \P{...} is relative to universe
+	p, err := passgen.CompileInput(passgen.GenerateInput{
+		Pattern:  []rune(`\P{L}{8}`),
+		Universe: []rune("abc123"),
+	})
+	is.NotErr(err)
+	is.True(math.Abs(p.Entropy()-8*math.Log2(3)) < 0.0001)
+	out, err := p.Generate()
+	is.NotErr(err)
+	for _, c := range out.Password {
+		is.AddMsg("char=%#v", string(c)).True(c >= '1' && c <= '3')
+	}
+	test(`[\p{Cyrillic}0-9]{8}`, 0, func(c rune) bool {
+		return unicode.Is(unicode.Cyrillic, c) || c >= '0' && c <= '9'
+	})
+}
@@ -0,0 +1,188 @@
+package passgen
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+var (
+	namedPatternsMutex sync.RWMutex
+	namedPatterns      = map[string][]rune{}
+)
+
+// RegisterPattern registers a named pattern, so that it can be used
+// inside other patterns as $use(name)
+// name must consist of letters, digits, underscore and hyphen, and must
+// not be already registered, see OverridePattern
+// the pattern is compiled when it's used, so it can use other named
+// patterns that are registered later
+func RegisterPattern(name string, pattern string) error {
+	return registerPattern(name, pattern, false)
+}
+
+// OverridePattern is like RegisterPattern, but replaces the registered
+// pattern with the same name, if there is any
+func OverridePattern(name string, pattern string) error {
+	return registerPattern(name, pattern, true)
+}
+
+func registerPattern(name string, pattern string, override bool) error {
+	err := checkPatternName(name)
+	if err != nil {
+		return err
+	}
+	namedPatternsMutex.Lock()
+	defer namedPatternsMutex.Unlock()
+	if !override && namedPatterns[name] != nil {
+		return fmt.Errorf("pattern %#v is already registered", name)
+	}
+	namedPatterns[name] = []rune(pattern)
+	return nil
+}
+
+// unregisterPattern removes the registered pattern with given name
+func unregisterPattern(name string) {
+	namedPatternsMutex.Lock()
+	defer namedPatternsMutex.Unlock()
+	delete(namedPatterns, name)
+}
+
+// PatternNames returns the sorted list of names of registered patterns
+func PatternNames() []string {
+	namedPatternsMutex.RLock()
+	names := make([]string, 0, len(namedPatterns))
+	for name := range namedPatterns {
+		names = append(names, name)
+	}
+	namedPatternsMutex.RUnlock()
+	slices.Sort(names)
+	return names
+}
+
+func checkPatternName(name string) error {
+	if name == "" {
+		return errors.New("pattern name is empty")
+	}
+	for _, c := range name {
+		if !isPatternNameChar(c) {
+			return fmt.Errorf("invalid character %q in pattern name %#v", c, name)
+		}
+	}
+	return nil
+}
+
+func isPatternNameChar(c rune) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	case c == '_', c == '-':
+		return true
+	}
+	return false
+}
+
+func getNamedPattern(name string) []rune {
+	namedPatternsMutex.RLock()
+	defer namedPatternsMutex.RUnlock()
+	return namedPatterns[name]
+}
+
+// useGenerator is the generator of $use(name), which generates
+// the named pattern, see RegisterPattern
+// the named pattern has its own groups, so backrefs like \1 inside it
+// refer to groups of the named pattern
+type useGenerator struct {
+	name string
+	root *RootGenerator
+}
+
+func newUseGenerator(s *State, arg []rune) (*useGenerator, error) {
+	name := string(arg)
+	if getNamedPattern(name) == nil {
+		s.errorOffset += int64(len(arg))
+		s.errorMarkLen = max(len(arg), 1)
+		return nil, s.errorValue("unknown pattern %#v", name)
+	}
+	return &useGenerator{name: name}, nil
+}
+
+// fork returns a copy of shared state for a separate pattern
+// that shares the limits, random source and used patterns, but not
+// the groups and error position
+func (ss *SharedState) fork() *SharedState {
+	ss2 := ss.Copy()
+	ss2.groupsOutput = map[uint64][]rune{}
+	ss2.definedGroups = map[uint64]bool{}
+	ss2.groupNames = map[string]uint64{}
+	ss2.absPos = 0
+	ss2.errorOffset = 0
+	ss2.errorMarkLen = 1
+	ss2.lastGroupId = 0
+	return ss2
+}
+
+func (g *useGenerator) compile(s *State) error {
+	name := g.name
+	markError := func() {
+		s.errorOffset += int64(len(name))
+		s.errorMarkLen = len(name)
+	}
+	pattern := getNamedPattern(name)
+	if pattern == nil {
+		markError()
+		return s.errorValue("unknown pattern %#v", name)
+	}
+	if s.usedPatterns[name] {
+		markError()
+		return s.errorValue("recursive use of pattern %#v", name)
+	}
+	s.usedPatterns[name] = true
+	defer delete(s.usedPatterns, name)
+	root, err := subCompile(NewState(s.SharedState.fork(), nil), pattern)
+	if err != nil {
+		markError()
+		return s.errorValue("error in pattern %#v: %v", name, err)
+	}
+	g.root = root
+	return nil
+}
+
+func (g *useGenerator) Generate(s *State) error {
+	if g.root == nil {
+		err := g.compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	s2 := NewState(s.SharedState.fork(), nil)
+	err := g.root.Generate(s2)
+	if err != nil {
+		return err
+	}
+	s.addOutput(s2.output)
+	return nil
+}
+
+func (g *useGenerator) Entropy(s *State) (float64, error) {
+	if g.root == nil {
+		return 0, s.errorUnknown(s_entropy_not_calc)
+	}
+	return g.root.Entropy(s)
+}
+
+func (g *useGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	if g.root == nil {
+		return nil, s.errorUnknown(s_entropy_not_calc)
+	}
+	node, err := g.root.explainEntropy(s)
+	if err != nil {
+		return nil, err
+	}
+	return &EntropyNode{
+		Detail:   fmt.Sprintf("entropy of pattern %#v", g.name),
+		Entropy:  node.Entropy,
+		Children: []*EntropyNode{node},
+	}, nil
+}
@@ -0,0 +1,75 @@
+package passgen_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestUsePattern(t *testing.T) {
+	is := is.New(t)
+	t.Cleanup(func() {
+		for _, name := range []string{"test-pin", "test-pair", "test-combo", "test-loop1", "test-loop2", "test-bad"} {
+			passgen.UnregisterPattern(name)
+		}
+	})
+	is.NotErr(passgen.RegisterPattern("test-pin", `[0-9]{4}`))
+	is.NotErr(passgen.RegisterPattern("test-pair", `([a-z]{3})-\1`))
+	is.NotErr(passgen.RegisterPattern("test-combo", `$use(test-pair):$use(test-pin)`))
+	is.NotErr(passgen.RegisterPattern("test-loop1", `a$use(test-loop2)`))
+	is.NotErr(passgen.RegisterPattern("test-loop2", `b$use(test-loop1)`))
+	is.NotErr(passgen.RegisterPattern("test-bad", `[a-z`))
+
+	{
+		p, err := passgen.Compile([]rune(`([A-Z]{2})$use(test-combo)\1`))
+		is.NotErr(err)
+		// 2*log2(26) + 3*log2(26) + 4*log2(10)
+		isFloatBetween(is, p.Entropy(), 36.78, 36.79)
+		out, err := p.Generate()
+		is.NotErr(err)
+		pw := string(out.Password)
+		is.Equal(16, len(pw))
+		is.Equal(pw[:2], pw[14:])
+		is.Equal(pw[2:5], pw[6:9])
+		is.Equal(":", pw[9:10])
+	}
+
+	is.ErrMsg(
+		passgen.RegisterPattern("test-pin", `[0-9]{6}`),
+		`pattern "test-pin" is already registered`,
+	)
+	is.ErrMsg(passgen.RegisterPattern("", `a`), "pattern name is empty")
+	is.NotErr(passgen.OverridePattern("test-pin", `[0-9]{6}`))
+	{
+		p, err := passgen.Compile([]rune(`$use(test-pin)`))
+		is.NotErr(err)
+		isFloatBetween(is, p.Entropy(), 19.93, 19.94)
+	}
+	is.ErrMsg(
+		passgen.RegisterPattern("a b", `a`),
+		`invalid character ' ' in pattern name "a b"`,
+	)
+
+	compileErr := func(pattern string) string {
+		_, err := passgen.Compile([]rune(pattern))
+		if err == nil {
+			t.Fatalf("pattern=%#v: error expected", pattern)
+		}
+		return err.Error()
+	}
+	is.Equal(
+		`value error near index 13: unknown pattern "test-none"`,
+		compileErr(`$use(test-none)`),
+	)
+	is.True(strings.Contains(
+		compileErr(`$use(test-loop1)`),
+		`recursive use of pattern "test-loop1"`,
+	))
+	is.Equal(
+		`value error near index 13: error in pattern "test-bad": syntax error near index 3: no character after '-'`,
+		compileErr(`x$use(test-bad)`),
+	)
+	is.True(strings.Contains(strings.Join(passgen.PatternNames(), " "), "test-combo"))
+}
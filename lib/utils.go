@@ -45,3 +45,35 @@ func hasRune(st []rune, c rune) bool {
 	}
 	return false
 }
+
+func runeSet(chars []rune) map[rune]bool {
+	set := make(map[rune]bool, len(chars))
+	for _, c := range chars {
+		set[c] = true
+	}
+	return set
+}
+
+func subtractRunes(chars []rune, exclude []rune) []rune {
+	ex_set := runeSet(exclude)
+	list := make([]rune, 0, len(chars))
+	for _, c := range chars {
+		if ex_set[c] {
+			continue
+		}
+		list = append(list, c)
+	}
+	return list
+}
+
+func intersectRunes(chars []rune, other []rune) []rune {
+	set := runeSet(other)
+	list := make([]rune, 0, len(chars))
+	for _, c := range chars {
+		if !set[c] {
+			continue
+		}
+		list = append(list, c)
+	}
+	return list
+}
@@ -0,0 +1,162 @@
+package passgen
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// weightedGenerator is the generator of $weighted(PATTERN1:W1|PATTERN2:W2|...)
+// that generates one of patterns, with probability proportional to its weight
+type weightedGenerator struct {
+	roots []*RootGenerator
+	parts [][]rune
+
+	// probList is the list of probabilities of branches
+	probList []float64
+	// entropyList is the list of entropy of branches
+	entropyList []float64
+}
+
+func newWeightedGenerator(argsStr []rune) (*weightedGenerator, error) {
+	parts, _, err := splitArgsStr(argsStr, '|')
+	if err != nil {
+		return nil, err
+	}
+	return &weightedGenerator{
+		parts: parts,
+	}, nil
+}
+
+// splitWeight splits "PATTERN:WEIGHT" into pattern and weight string
+// weight string is empty if part does not end with ":" and a number
+func splitWeight(part []rune) ([]rune, string) {
+	str := string(part)
+	i := strings.LastIndexByte(str, ':')
+	if i < 0 || i == len(str)-1 {
+		return part, ""
+	}
+	weightStr := str[i+1:]
+	if strings.Trim(weightStr, "0123456789.") != "" {
+		return part, ""
+	}
+	return []rune(str[:i]), weightStr
+}
+
+func (g *weightedGenerator) compile(s *State) error {
+	roots := make([]*RootGenerator, len(g.parts))
+	weights := make([]float64, len(g.parts))
+	total := 0.0
+	for i, part := range g.parts {
+		if i > 0 {
+			// the | before branch
+			s.errorOffset++
+		}
+		pattern, weightStr := splitWeight(part)
+		if weightStr == "" {
+			s.errorOffset += int64(len(part))
+			s.errorMarkLen = max(len(part), 1)
+			return s.errorValue("weighted: branch %#v has no weight, must be like PATTERN:WEIGHT", string(part))
+		}
+		weight, err := strconv.ParseFloat(weightStr, 64)
+		if err != nil || weight <= 0 || math.IsInf(weight, 0) {
+			s.errorOffset += int64(len(part))
+			s.errorMarkLen = len(weightStr)
+			return s.errorValue("weighted: invalid weight %#v, must be a positive number", weightStr)
+		}
+		root, err := subCompile(s, pattern)
+		if err != nil {
+			return err
+		}
+		s.errorOffset += int64(len(weightStr) + 1)
+		roots[i] = root
+		weights[i] = weight
+		total += weight
+	}
+	probList := make([]float64, len(roots))
+	entropyList := make([]float64, len(roots))
+	for i, root := range roots {
+		entropy, err := root.Entropy(s)
+		if err != nil {
+			return err
+		}
+		probList[i] = weights[i] / total
+		entropyList[i] = entropy
+	}
+	g.roots = roots
+	g.probList = probList
+	g.entropyList = entropyList
+	return nil
+}
+
+func (g *weightedGenerator) Generate(s *State) error {
+	if g.roots == nil {
+		err := g.compile(s)
+		if err != nil {
+			return err
+		}
+	}
+	logProbList := make([]float64, len(g.probList))
+	for i, prob := range g.probList {
+		logProbList[i] = math.Log2(prob)
+	}
+	i, err := s.randWeighted(logProbList)
+	if err != nil {
+		return err
+	}
+	return g.roots[i].Generate(s)
+}
+
+// minEntropy returns minimum of H_i - log2(p_i) where H_i is entropy and
+// p_i is probability of branch i, which is the min-entropy of output
+// if outputs of branches are distinct and each branch is uniform
+func (g *weightedGenerator) minEntropy() float64 {
+	minEntropy := math.Inf(1)
+	for i, entropy := range g.entropyList {
+		minEntropy = min(minEntropy, entropy-math.Log2(g.probList[i]))
+	}
+	return minEntropy
+}
+
+// shannonEntropy returns sum of p_i * (H_i - log2(p_i)), which is the
+// Shannon entropy of output with the same assumptions as minEntropy
+func (g *weightedGenerator) shannonEntropy() float64 {
+	entropy := 0.0
+	for i, branchEntropy := range g.entropyList {
+		prob := g.probList[i]
+		entropy += prob * (branchEntropy - math.Log2(prob))
+	}
+	return entropy
+}
+
+// Entropy returns the min-entropy of weighted choice of branches, which is
+// a conservative value, see minEntropy
+func (g *weightedGenerator) Entropy(s *State) (float64, error) {
+	if g.roots == nil {
+		return 0, s.errorUnknown(s_entropy_not_calc)
+	}
+	return g.minEntropy(), nil
+}
+
+func (g *weightedGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	if g.roots == nil {
+		return nil, s.errorUnknown(s_entropy_not_calc)
+	}
+	node := &EntropyNode{
+		Detail: fmt.Sprintf(
+			"minimum of entropy - log2(probability) of branches, Shannon entropy is %.2f bits",
+			g.shannonEntropy(),
+		),
+		Size:    len(g.roots),
+		Entropy: g.minEntropy(),
+	}
+	for _, root := range g.roots {
+		childNode, err := root.explainEntropy(s)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, childNode)
+	}
+	return node, nil
+}
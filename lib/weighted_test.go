@@ -0,0 +1,82 @@
+package passgen_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestWeighted(t *testing.T) {
+	is := is.New(t)
+	p, err := passgen.Compile([]rune(`$weighted(abc:3|[:digit:]{4}:1)`))
+	is.NotErr(err)
+	// min-entropy is -log2(3/4) of "abc"
+	is.True(math.Abs(p.Entropy()-math.Log2(4.0/3)) < 0.0001)
+	node, err := p.ExplainEntropy()
+	is.NotErr(err)
+	// Shannon: 3/4 * log2(4/3) + 1/4 * (log2(10^4) + 2)
+	shannon := 0.75*math.Log2(4.0/3) + 0.25*(4*math.Log2(10)+2)
+	is.True(strings.HasSuffix(node.Children[0].Detail, "Shannon entropy is 4.13 bits"))
+	is.True(math.Abs(shannon-4.13) < 0.005)
+	count := 0
+	for range 1000 {
+		out, err := p.Generate()
+		is.NotErr(err)
+		if string(out.Password) == "abc" {
+			count++
+			continue
+		}
+		is.Equal(len(out.Password), 4)
+	}
+	is.AddMsg("count=%d", count).True(count > 650 && count < 850)
+
+	test := func(pattern string, entropy float64) {
+		p, err := passgen.Compile([]rune(pattern))
+		is := is.AddMsg("pattern=%#v", pattern)
+		is.NotErr(err)
+		is.True(math.Abs(p.Entropy()-entropy) < 0.0001)
+		_, err = p.Generate()
+		is.NotErr(err)
+	}
+	// equal weights are like alteration
+	test(`$weighted([:digit:]{4}:1|[:digit:]{4}:1)`, 1+4*math.Log2(10))
+	test(`$weighted([:alpha:]:0.5|[:digit:]:1.5)`, math.Log2(10)-math.Log2(0.75))
+	test(`x$weighted(a\:b:1)`, 0)
+	test(`$weighted(([a-z]|1):1|[[:digit:]|]:2)`, math.Log2(3)+1)
+	// probability of having digit is 1/4, min-entropy is of [0-9]{8} branch
+	test(`$require($weighted([a-z]{8}:3|[0-9]{8}:1),[:digit:])`, 8*math.Log2(10))
+}
+
+func TestWeightedError(t *testing.T) {
+	testGenErr(t, &genErrCase{
+		Pattern: `$weighted()`,
+		Error:   `         ^ value error: weighted: branch "" has no weight, must be like PATTERN:WEIGHT`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$weighted(abc|def:1)`,
+		Error:   `          ^^^ value error: weighted: branch "abc" has no weight, must be like PATTERN:WEIGHT`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$weighted(abc:1|def)`,
+		Error:   `                ^^^ value error: weighted: branch "def" has no weight, must be like PATTERN:WEIGHT`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$weighted(abc:1|[:digit:])`,
+		Error:   `                ^^^^^^^^^ value error: weighted: branch "[:digit:]" has no weight, must be like PATTERN:WEIGHT`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$weighted(abc:0)`,
+		Error:   `              ^ value error: weighted: invalid weight "0", must be a positive number`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$weighted(abc:1.2.3)`,
+		Error:   `              ^^^^^ value error: weighted: invalid weight "1.2.3", must be a positive number`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$weighted(a:1|[:x:]:1)`,
+		Error:   `               ^^^ value error: invalid character class "x"`,
+	})
+}
@@ -0,0 +1,203 @@
+package passgen
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EFF word lists for passphrases, by Electronic Frontier Foundation
+// licensed under CC BY 3.0 US, see
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases
+
+//go:embed wordlists/eff_large_wordlist.txt
+var effLargeWordList string
+
+//go:embed wordlists/eff_short_wordlist_2_0.txt
+var effShortWordList string
+
+// parseDicewareList returns the words of a diceware list, which has
+// dice rolls and word on each line, separated by tab
+func parseDicewareList(text string) []string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	words := make([]string, len(lines))
+	for i, line := range lines {
+		_, word, _ := strings.Cut(line, "\t")
+		words[i] = word
+	}
+	return words
+}
+
+// wordLists is the map of names of embedded word lists of $word
+var wordLists = map[string]func() []string{
+	"large": sync.OnceValue(func() []string {
+		return parseDicewareList(effLargeWordList)
+	}),
+	"short": sync.OnceValue(func() []string {
+		return parseDicewareList(effShortWordList)
+	}),
+}
+
+const (
+	// wordOptionCapitalize capitalizes one random word
+	wordOptionCapitalize = "capitalize"
+	// wordOptionDigit appends a random digit to one random word
+	wordOptionDigit = "digit"
+)
+
+// wordGenerator is the generator of $word(LIST, N[, SEP[, OPTIONS...]])
+// that generates N random words of an embedded word list, joined by SEP
+type wordGenerator struct {
+	listName  string
+	words     []string
+	wordCount int64
+	sep       string
+
+	capitalize bool
+	digit      bool
+}
+
+// argEndOffset returns the error offset of end of i'th argument, relative
+// to start of arguments
+func argEndOffset(args [][]rune, indexList []uint64, i int) int64 {
+	if i == 0 {
+		return int64(len(args[0]))
+	}
+	return int64(indexList[i]) + 1 + int64(len(args[i]))
+}
+
+func newWordGenerator(s *State, argsStr []rune) (*wordGenerator, error) {
+	args, indexList, err := splitArgsStr(argsStr, ',')
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 2 {
+		s.errorOffset += int64(len(argsStr) + 1)
+		return nil, s.errorArg("word: at least 2 arguments are required")
+	}
+	argError := func(i int, msg string, msgArgs ...any) error {
+		s.errorOffset += argEndOffset(args, indexList, i)
+		s.errorMarkLen = max(len(args[i]), 1)
+		return s.errorValue(msg, msgArgs...)
+	}
+	listName := strings.TrimSpace(string(args[0]))
+	getWords := wordLists[listName]
+	if getWords == nil {
+		return nil, argError(0, "word: invalid word list %#v, must be large or short", listName)
+	}
+	wordCountStr := strings.TrimSpace(string(args[1]))
+	wordCount, err := strconv.ParseInt(wordCountStr, 10, 64)
+	if err != nil || wordCount < 1 {
+		return nil, argError(1, "word: invalid number of words %#v", wordCountStr)
+	}
+	g := &wordGenerator{
+		listName:  listName,
+		words:     getWords(),
+		wordCount: wordCount,
+		sep:       " ",
+	}
+	if len(args) > 2 {
+		g.sep = string(args[2])
+	}
+	// words joined by an empty separator are ambiguous, and make the
+	// number of distinct outputs less than what entropy assumes
+	if wordCount > 1 && g.sep == "" {
+		return nil, argError(2, "word: separator can not be empty for more than 1 word")
+	}
+	for i := 3; i < len(args); i++ {
+		option := strings.TrimSpace(string(args[i]))
+		switch option {
+		case wordOptionCapitalize:
+			g.capitalize = true
+		case wordOptionDigit:
+			g.digit = true
+		default:
+			return nil, argError(
+				i, "word: invalid option %#v, must be %s or %s",
+				option, wordOptionCapitalize, wordOptionDigit,
+			)
+		}
+	}
+	return g, nil
+}
+
+func (g *wordGenerator) Generate(s *State) error {
+	words := make([]string, g.wordCount)
+	for i := range words {
+		index, err := s.randInt(int64(len(g.words)))
+		if err != nil {
+			return err
+		}
+		words[i] = g.words[index]
+	}
+	if g.capitalize {
+		i, err := s.randInt(g.wordCount)
+		if err != nil {
+			return err
+		}
+		words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+	}
+	if g.digit {
+		i, err := s.randInt(g.wordCount)
+		if err != nil {
+			return err
+		}
+		digit, err := s.randInt(10)
+		if err != nil {
+			return err
+		}
+		words[i] += strconv.FormatInt(digit, 10)
+	}
+	s.addOutput([]rune(strings.Join(words, g.sep)))
+	return nil
+}
+
+// optionsEntropy returns the entropy of options: log2(N) for choosing
+// the capitalized word, and log2(10*N) for choosing the word and digit
+func (g *wordGenerator) optionsEntropy() float64 {
+	entropy := 0.0
+	if g.capitalize {
+		entropy += math.Log2(float64(g.wordCount))
+	}
+	if g.digit {
+		entropy += math.Log2(float64(10 * g.wordCount))
+	}
+	return entropy
+}
+
+func (g *wordGenerator) Entropy(_ *State) (float64, error) {
+	return float64(g.wordCount)*math.Log2(float64(len(g.words))) + g.optionsEntropy(), nil
+}
+
+func (g *wordGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	entropy, err := g.Entropy(s)
+	if err != nil {
+		return nil, err
+	}
+	detail := fmt.Sprintf(
+		"%d words of %d (EFF %s list), %.2f bits per word",
+		g.wordCount,
+		len(g.words),
+		g.listName,
+		math.Log2(float64(len(g.words))),
+	)
+	options := []string{}
+	if g.capitalize {
+		options = append(options, wordOptionCapitalize)
+	}
+	if g.digit {
+		options = append(options, wordOptionDigit)
+	}
+	if len(options) > 0 {
+		detail += fmt.Sprintf(", %s: %.2f bits", strings.Join(options, " and "), g.optionsEntropy())
+	}
+	return &EntropyNode{
+		Detail:  detail,
+		Size:    len(g.words),
+		Count:   g.wordCount,
+		Entropy: entropy,
+	}, nil
+}
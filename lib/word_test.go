@@ -0,0 +1,102 @@
+package passgen_test
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func TestWordLists(t *testing.T) {
+	is := is.New(t)
+	large := passgen.WordList("large")
+	is.Equal(len(large), 7776)
+	is.Equal(large[0], "abacus")
+	is.Equal(large[7775], "zoom")
+	short := passgen.WordList("short")
+	is.Equal(len(short), 1296)
+	is.Equal(short[0], "aardvark")
+	is.Equal(short[1295], "zucchini")
+}
+
+func TestWord(t *testing.T) {
+	is := is.New(t)
+	large := passgen.WordList("large")
+	short := passgen.WordList("short")
+	test := func(pattern string, sep string, list []string, count int, entropy float64, check func([]string)) {
+		is := is.AddMsg("pattern=%#v", pattern)
+		p, err := passgen.Compile([]rune(pattern))
+		is.NotErr(err)
+		is.True(math.Abs(p.Entropy()-entropy) < 0.0001)
+		for range 20 {
+			out, err := p.Generate()
+			is.NotErr(err)
+			words := strings.Split(string(out.Password), sep)
+			is.Equal(len(words), count)
+			if check != nil {
+				check(words)
+				continue
+			}
+			for _, word := range words {
+				is.AddMsg("word=%#v", word).True(slices.Contains(list, word))
+			}
+		}
+	}
+	test(`$word(large, 4)`, " ", large, 4, 4*math.Log2(7776), nil)
+	test(`$word(short,6,.)`, ".", short, 6, 6*math.Log2(1296), nil)
+	test(`$word(large,3,\,)`, ",", large, 3, 3*math.Log2(7776), nil)
+	test(`$word(large,5,_,capitalize,digit)`, "_", large, 5, 5*math.Log2(7776)+math.Log2(5)+math.Log2(50), func(words []string) {
+		upper, digits := 0, 0
+		for _, word := range words {
+			if unicode.IsUpper(rune(word[0])) {
+				upper++
+			}
+			word = strings.ToLower(word)
+			if last := word[len(word)-1]; last >= '0' && last <= '9' {
+				digits++
+				word = word[:len(word)-1]
+			}
+			is.AddMsg("word=%#v", word).True(slices.Contains(large, word))
+		}
+		is.Equal(upper, 1)
+		is.Equal(digits, 1)
+	})
+	test(`$word(short,1,-, capitalize)`, "-", short, 1, math.Log2(1296), func(words []string) {
+		is.True(unicode.IsUpper(rune(words[0][0])))
+	})
+}
+
+func TestWordError(t *testing.T) {
+	testGenErr(t, &genErrCase{
+		Pattern: `$word()`,
+		Error:   `      ^ argument error: word: at least 2 arguments are required`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$word(large)`,
+		Error:   `           ^ argument error: word: at least 2 arguments are required`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$word(medium,4)`,
+		Error:   `      ^^^^^^ value error: word: invalid word list "medium", must be large or short`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$word(large,x)`,
+		Error:   `            ^ value error: word: invalid number of words "x"`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$word(large, 0)`,
+		Error:   `            ^^ value error: word: invalid number of words "0"`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$word(large,4, ,upper)`,
+		Error:   `                ^^^^^ value error: word: invalid option "upper", must be capitalize or digit`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern: `$word(large,4,)`,
+		Error:   `             ^ value error: word: separator can not be empty for more than 1 word`,
+	})
+}
@@ -0,0 +1,185 @@
+package passgen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// resolveFilePath returns the real path of file, after checking that file
+// access is allowed, and that file is inside fileDir if it's set
+func resolveFilePath(s *State, path string) (string, error) {
+	if !s.allowFiles {
+		return "", errors.New("reading files is not allowed")
+	}
+	if s.fileDir == "" {
+		return path, nil
+	}
+	dir, err := filepath.Abs(s.fileDir)
+	if err == nil {
+		dir, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid file directory %#v: %w", s.fileDir, err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("can not read file %#v: %w", path, err)
+	}
+	rel, err := filepath.Rel(dir, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %#v is not inside directory %#v", path, s.fileDir)
+	}
+	return realPath, nil
+}
+
+// readLinesFile returns the unique non-empty lines of file, in the
+// order of first appearance, leading and trailing spaces are removed
+func readLinesFile(s *State, path string) ([]string, error) {
+	realPath, err := resolveFilePath(s, path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(realPath)
+	if err != nil {
+		return nil, fmt.Errorf("can not read file %#v: %w", path, err)
+	}
+	lines := []string{}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("file %#v is empty", path)
+	}
+	return lines, nil
+}
+
+// lineFileGenerator is the generator of $wordfile(PATH, N[, SEP]) and
+// $line(PATH), that generates N random lines of file, joined by SEP
+type lineFileGenerator struct {
+	path  string
+	lines []string
+	count int64
+	sep   string
+}
+
+func newLineGenerator(s *State, arg []rune) (*lineFileGenerator, error) {
+	path := strings.TrimSpace(string(arg))
+	lines, err := readLinesFile(s, path)
+	if err != nil {
+		s.errorOffset += int64(len(arg))
+		s.errorMarkLen = max(len(arg), 1)
+		return nil, s.errorValue("line: %v", err)
+	}
+	return &lineFileGenerator{
+		path:  path,
+		lines: lines,
+		count: 1,
+	}, nil
+}
+
+func newWordFileGenerator(s *State, argsStr []rune) (*lineFileGenerator, error) {
+	args, indexList, err := splitArgsStr(argsStr, ',')
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < 2 {
+		s.errorOffset += int64(len(argsStr) + 1)
+		return nil, s.errorArg("wordfile: at least 2 arguments are required")
+	}
+	if len(args) > 3 {
+		s.errorOffset += argEndOffset(args, indexList, 3)
+		s.errorMarkLen = max(len(args[3]), 1)
+		return nil, s.errorArg("wordfile: at most 3 arguments are allowed")
+	}
+	countStr := strings.TrimSpace(string(args[1]))
+	count, err := strconv.ParseInt(countStr, 10, 64)
+	if err != nil || count < 1 {
+		s.errorOffset += argEndOffset(args, indexList, 1)
+		s.errorMarkLen = max(len(args[1]), 1)
+		return nil, s.errorValue("wordfile: invalid number of words %#v", countStr)
+	}
+	path := strings.TrimSpace(string(args[0]))
+	lines, err := readLinesFile(s, path)
+	if err != nil {
+		s.errorOffset += argEndOffset(args, indexList, 0)
+		s.errorMarkLen = max(len(args[0]), 1)
+		return nil, s.errorValue("wordfile: %v", err)
+	}
+	g := &lineFileGenerator{
+		path:  path,
+		lines: lines,
+		count: count,
+		sep:   " ",
+	}
+	if len(args) > 2 {
+		g.sep = string(args[2])
+	}
+	// lines joined by an empty separator, or by a separator they contain
+	// are ambiguous, and make the number of distinct outputs less than
+	// what entropy assumes
+	if count > 1 && g.sep == "" {
+		s.errorOffset += argEndOffset(args, indexList, 2)
+		s.errorMarkLen = 1
+		return nil, s.errorValue("wordfile: separator can not be empty for more than 1 word")
+	}
+	if count > 1 {
+		for _, line := range lines {
+			if strings.Contains(line, g.sep) {
+				s.errorOffset += argEndOffset(args, indexList, 0)
+				s.errorMarkLen = max(len(args[0]), 1)
+				return nil, s.errorValue("wordfile: line %#v of file contains separator %#v", line, g.sep)
+			}
+		}
+	}
+	return g, nil
+}
+
+func (g *lineFileGenerator) Generate(s *State) error {
+	lines := make([]string, g.count)
+	for i := range lines {
+		index, err := s.randInt(int64(len(g.lines)))
+		if err != nil {
+			return err
+		}
+		lines[i] = g.lines[index]
+	}
+	s.addOutput([]rune(strings.Join(lines, g.sep)))
+	return nil
+}
+
+func (g *lineFileGenerator) Entropy(_ *State) (float64, error) {
+	return float64(g.count) * math.Log2(float64(len(g.lines))), nil
+}
+
+func (g *lineFileGenerator) explainEntropy(s *State) (*EntropyNode, error) {
+	entropy, err := g.Entropy(s)
+	if err != nil {
+		return nil, err
+	}
+	return &EntropyNode{
+		Detail: fmt.Sprintf(
+			"%d of %d unique lines of %#v, %.2f bits per line",
+			g.count,
+			len(g.lines),
+			g.path,
+			math.Log2(float64(len(g.lines))),
+		),
+		Size:    len(g.lines),
+		Count:   g.count,
+		Entropy: entropy,
+	}, nil
+}
@@ -0,0 +1,157 @@
+package passgen_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ilius/is/v2"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	err := os.WriteFile(path, []byte(content), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWordFile(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "names.txt")
+	// 4 unique lines
+	writeTestFile(t, path, "apollo\r\ngemini\n\n  mercury \napollo\nartemis\ngemini\n")
+	names := []string{"apollo", "gemini", "mercury", "artemis"}
+	test := func(pattern string, fileDir string, sep string, count int) {
+		is := is.AddMsg("pattern=%#v", pattern)
+		p, err := passgen.CompileInput(passgen.GenerateInput{
+			Pattern:    []rune(pattern),
+			AllowFiles: true,
+			FileDir:    fileDir,
+		})
+		is.NotErr(err)
+		is.True(math.Abs(p.Entropy()-float64(count)*2) < 0.0001)
+		for range 20 {
+			out, err := p.Generate()
+			is.NotErr(err)
+			words := strings.Split(string(out.Password), sep)
+			is.Equal(len(words), count)
+			for _, word := range words {
+				is.AddMsg("word=%#v", word).True(slices.Contains(names, word))
+			}
+		}
+	}
+	test(`$line(`+path+`)`, "", "\n", 1)
+	test(`$wordfile(`+path+`,3,-)`, "", "-", 3)
+	test(`$wordfile(`+path+`, 5)`, "", " ", 5)
+	test(`$line(names.txt)`, dir, "\n", 1)
+	test(`$line(`+path+`)`, dir, "\n", 1)
+	test(`$wordfile(names.txt,2,\,)`, dir, ",", 2)
+}
+
+func TestWordFileError(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "empty.txt"), "\n \n\n")
+	writeTestFile(t, filepath.Join(dir, "words.txt"), "a\nb\n")
+	writeTestFile(t, filepath.Join(dir, "phrases.txt"), "a b\nc-d\n")
+	otherDir := t.TempDir()
+	otherPath := filepath.Join(otherDir, "secret.txt")
+	writeTestFile(t, otherPath, "secret\n")
+	err := os.Symlink(otherPath, filepath.Join(dir, "link.txt"))
+	is.NotErr(err)
+
+	testGenErr(t, &genErrCase{
+		Pattern: `$line(words.txt)`,
+		FileDir: dir,
+		Error:   `      ^^^^^^^^^ value error: line: reading files is not allowed`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern:    `$line(empty.txt)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `      ^^^^^^^^^ value error: line: file "empty.txt" is empty`,
+	})
+	missingPath := filepath.Join(dir, "missing.txt")
+	testGenErr(t, &genErrCase{
+		Pattern:    `$line(missing.txt)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `      ^^^^^^^^^^^ value error: line: can not read file "` + missingPath + `": lstat ` + missingPath + `: no such file or directory`,
+	})
+	notInside := `" is not inside directory "` + dir + `"`
+	testGenErr(t, &genErrCase{
+		Pattern:    `$line(` + otherPath + `)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `      ` + strings.Repeat("^", len(otherPath)) + ` value error: line: file "` + otherPath + notInside,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern:    `$line(link.txt)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `      ^^^^^^^^ value error: line: file "` + filepath.Join(dir, "link.txt") + notInside,
+	})
+	relPath := "../" + filepath.Base(otherDir) + "/secret.txt"
+	testGenErr(t, &genErrCase{
+		Pattern:    `$line(` + relPath + `)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `      ` + strings.Repeat("^", len(relPath)) + ` value error: line: file "` + otherPath + notInside,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern:    `$wordfile(words.txt)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `                   ^ argument error: wordfile: at least 2 arguments are required`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern:    `$wordfile(words.txt,0)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `                    ^ value error: wordfile: invalid number of words "0"`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern:    `$wordfile(words.txt,2,-,x)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `                        ^ argument error: wordfile: at most 3 arguments are allowed`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern:    `$wordfile(empty.txt,2)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `          ^^^^^^^^^ value error: wordfile: file "empty.txt" is empty`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern:    `$wordfile(phrases.txt,2)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `          ^^^^^^^^^^^ value error: wordfile: line "a b" of file contains separator " "`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern:    `$wordfile(phrases.txt,2,-)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `          ^^^^^^^^^^^ value error: wordfile: line "c-d" of file contains separator "-"`,
+	})
+	testGenErr(t, &genErrCase{
+		Pattern:    `$wordfile(words.txt,2,)`,
+		AllowFiles: true,
+		FileDir:    dir,
+		Error:      `                     ^ value error: wordfile: separator can not be empty for more than 1 word`,
+	})
+	for _, pattern := range []string{`$wordfile(phrases.txt,2,_)`, `$wordfile(phrases.txt,1)`} {
+		_, err := passgen.CompileInput(passgen.GenerateInput{
+			Pattern:    []rune(pattern),
+			AllowFiles: true,
+			FileDir:    dir,
+		})
+		is.AddMsg("pattern=%#v", pattern).NotErr(err)
+	}
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 
 	passgen "github.com/ilius/repassgen/lib"
 	"github.com/ilius/repassgen/xflag"
@@ -28,6 +34,89 @@ type ExitCodeIface interface {
 	ExitCode() int
 }
 
+// explainFlag is the value of -explain flag, which can be used as a bool
+// flag (-explain) for text output, or as -explain=json for JSON output
+type explainFlag string
+
+func (f *explainFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return string(*f)
+}
+
+func (f *explainFlag) Set(value string) error {
+	switch value {
+	case "true", "text":
+		*f = "text"
+	case "json":
+		*f = "json"
+	case "false", "":
+		*f = ""
+	default:
+		return fmt.Errorf("invalid explain format %#v, must be text or json", value)
+	}
+	return nil
+}
+
+func (f *explainFlag) IsBoolFlag() bool {
+	return true
+}
+
+// classFlag is the value of repeatable -class flag: NAME=CHARS
+type classFlag []string
+
+func (f *classFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, " ")
+}
+
+func (f *classFlag) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("invalid class %#v, must be in NAME=CHARS format", value)
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// charClasses returns the classes of -class flags, to be used as
+// GenerateInput.CharClasses, so they are not registered globally
+func (f classFlag) charClasses() (map[string][]rune, error) {
+	classes := make(map[string][]rune, len(f))
+	for _, class := range f {
+		name, chars, _ := strings.Cut(class, "=")
+		if _, ok := classes[name]; ok {
+			return nil, fmt.Errorf("character class %#v is defined more than once", name)
+		}
+		classes[name] = []rune(chars)
+	}
+	return classes, nil
+}
+
+// printExplain prints the entropy breakdown node, and with -entropy
+// the entropy line before it in text format, JSON format already has
+// the entropy of pattern in its root node
+func printExplain(stdout io.Writer, node *passgen.EntropyNode, format explainFlag, calcEntropy bool) error {
+	if format == "json" {
+		jsonBytes, err := json.MarshalIndent(node, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = stdout.Write(append(jsonBytes, '\n'))
+		return err
+	}
+	if calcEntropy {
+		err := printEntropy(stdout, node.Entropy)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(stdout, node.String())
+	return err
+}
+
 func Main(stdout io.Writer, args []string) {
 	flagSet := &flag.FlagSet{}
 
@@ -36,6 +125,74 @@ func Main(stdout io.Writer, args []string) {
 		false,
 		"repassgen [-entropy] PATTERN",
 	)
+	countFlag := flagSet.Int(
+		"n",
+		1,
+		"number of passwords to generate, one per line",
+	)
+	nullFlag := flagSet.Bool(
+		"null",
+		false,
+		"separate passwords with NUL character instead of newline",
+	)
+	uniformFlag := flagSet.Bool(
+		"uniform",
+		false,
+		"make all passwords matching the pattern equally likely, by weighting lengths of {M,N} and branches of (A|B) by their keyspace size",
+	)
+	universeFlag := flagSet.String(
+		"universe",
+		"",
+		"character class, Unicode script or category that negated classes like [^abc] are complemented against, default is printable ASCII",
+	)
+	excludeFlag := flagSet.String(
+		"exclude",
+		"",
+		"characters to remove from every character class of pattern (like [:alnum:], \\w or [a-z]) and from $byte hex digits",
+	)
+	unambiguousFlag := flagSet.Bool(
+		"unambiguous",
+		false,
+		"exclude characters that are easily confused with each other: "+passgen.AmbiguousChars,
+	)
+	allowFilesFlag := flagSet.Bool(
+		"allow-files",
+		false,
+		"allow $wordfile and $line to read any file, -file-dir also allows reading files",
+	)
+	fileDirFlag := flagSet.String(
+		"file-dir",
+		"",
+		"only allow $wordfile and $line to read files inside this directory, relative paths are relative to it",
+	)
+	var classes classFlag
+	flagSet.Var(
+		&classes,
+		"class",
+		"define a named character class as NAME=CHARS, to be used as [:NAME:] in pattern, can be repeated",
+	)
+	var explain explainFlag
+	flagSet.Var(
+		&explain,
+		"explain",
+		"show entropy breakdown of pattern instead of generating passwords, use -explain=json for JSON output",
+	)
+
+	configFlag := flagSet.String(
+		"config",
+		"",
+		"path to config file of named patterns, default: "+defaultConfigPath(),
+	)
+	listPatternsFlag := flagSet.Bool(
+		"patterns",
+		false,
+		"list named patterns of config file",
+	)
+	policyFlag := flagSet.String(
+		"policy",
+		"",
+		"path to policy file (JSON or TOML), to generate passwords that comply with it, or to check that PATTERN only generates compliant passwords",
+	)
 
 	err := xflag.ParseToEnd(flagSet, args[1:])
 	if err != nil {
@@ -43,17 +200,137 @@ func Main(stdout io.Writer, args []string) {
 		os.Exit(2)
 	}
 
-	if len(flagSet.Args()) != 1 {
+	var policy *passgen.Policy
+	if *policyFlag != "" {
+		policy, err = loadPolicy(*policyFlag)
+		if err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(2)
+		}
+	}
+
+	if !*listPatternsFlag && len(flagSet.Args()) != 1 && !(policy != nil && len(flagSet.Args()) == 0) {
 		os.Stderr.WriteString("Need exactly one pattern (as positional argument)\n")
 		os.Exit(2)
 	}
 
 	calcEnropy := entropyFlag != nil && *entropyFlag
 
-	pattern := flagSet.Arg(0)
-	out, _, err := passgen.Generate(passgen.GenerateInput{
-		Pattern: []rune(pattern),
-	})
+	charClasses, err := classes.charClasses()
+	if err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(2)
+	}
+
+	var universe []rune
+	if chars, ok := charClasses[*universeFlag]; ok {
+		universe = slices.Clone(chars)
+		slices.Sort(universe)
+		universe = slices.Compact(universe)
+	} else if *universeFlag != "" {
+		universe, err = passgen.Universe(*universeFlag)
+		if err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(2)
+		}
+	}
+
+	exclude := []rune(*excludeFlag)
+	if *unambiguousFlag {
+		exclude = append(exclude, []rune(passgen.AmbiguousChars)...)
+	}
+
+	count := *countFlag
+	if count < 1 {
+		os.Stderr.WriteString("Invalid count, must be a positive integer\n")
+		os.Exit(2)
+	}
+	sep := "\n"
+	if *nullFlag {
+		sep = "\x00"
+	}
+
+	limits := passgen.Limits{}
+	maxLengthStr := os.Getenv("REPASSGEN_MAX_LENGTH")
+	if maxLengthStr != "" {
+		maxLength, err := strconv.Atoi(maxLengthStr)
+		if err != nil {
+			os.Stderr.WriteString("invalid REPASSGEN_MAX_LENGTH: must be an integer\n")
+			os.Exit(2)
+		}
+		limits.MaxOutputLength = maxLength
+		limits.TruncateOutput = true
+	}
+
+	input := passgen.GenerateInput{
+		Limits:      limits,
+		Uniform:     *uniformFlag,
+		Universe:    universe,
+		Exclude:     exclude,
+		CharClasses: charClasses,
+		AllowFiles:  *allowFilesFlag || *fileDirFlag != "",
+		FileDir:     *fileDirFlag,
+	}
+
+	// config file is only loaded if it's given, or its patterns are used
+	conf := &Config{}
+	if *configFlag != "" || *listPatternsFlag || usesConfig(flagSet.Arg(0)) {
+		configPath := *configFlag
+		if configPath == "" {
+			configPath = defaultConfigPath()
+		}
+		conf, err = loadConfig(configPath, *configFlag != "", input)
+		if err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(2)
+		}
+	}
+	if *listPatternsFlag {
+		err := printPatterns(stdout, conf)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	var pattern string
+	var namedPattern *NamedPattern
+	// policyPattern is true if pattern is made from policy
+	policyPattern := policy != nil && flagSet.NArg() == 0
+	if policyPattern {
+		pattern, err = policy.Pattern()
+	} else {
+		pattern, namedPattern, err = conf.resolvePattern(flagSet.Arg(0))
+	}
+	if err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(2)
+	}
+	input.Pattern = []rune(pattern)
+	p, err := passgen.CompileInput(input)
+	if err == nil && namedPattern != nil {
+		err = namedPattern.checkEntropy(p)
+	}
+	if err == nil && policy != nil && !policyPattern {
+		err = p.CheckPolicy(policy)
+	}
+	var outList []*passgen.GenerateOutput
+	var explainNode *passgen.EntropyNode
+	if err == nil && explain != "" {
+		if policyPattern {
+			// include entropy adjustment of GeneratePolicy
+			explainNode, err = p.ExplainPolicyEntropy(policy)
+		} else {
+			explainNode, err = p.ExplainEntropy()
+		}
+	}
+	if err == nil && explain == "" {
+		if policyPattern {
+			outList, err = generateWithPolicy(p, policy, count)
+		} else {
+			outList, err = p.GenerateMany(count, runtime.GOMAXPROCS(0))
+		}
+	}
 	if err != nil {
 		printError(err, pattern)
 		ec, ok := err.(ExitCodeIface)
@@ -63,29 +340,39 @@ func Main(stdout io.Writer, args []string) {
 		os.Exit(1)
 	}
 
-	_, err = fmt.Fprintln(stdout, string(out.Password))
+	if explain != "" {
+		err := printExplain(stdout, explainNode, explain, calcEnropy)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	writer := bufio.NewWriter(stdout)
+	for _, out := range outList {
+		_, err = writer.WriteString(string(out.Password) + sep)
+		if err != nil {
+			panic(err)
+		}
+	}
+	err = writer.Flush()
 	if err != nil {
 		panic(err)
 	}
+	out := outList[0]
 	if calcEnropy {
-		if os.Getenv("REPASSGEN_FLOAT_ENTROPY") == "true" {
-			_, err := fmt.Fprintf(
-				stdout,
-				"Entropy of pattern: %.2f bits\n",
-				out.PatternEntropy,
-			)
-			if err != nil {
-				panic(err)
-			}
-		} else {
-			_, err := fmt.Fprintf(
-				stdout,
-				"Entropy of pattern: %d bits\n",
-				int(out.PatternEntropy),
-			)
-			if err != nil {
-				panic(err)
-			}
+		err := printEntropy(stdout, out.PatternEntropy)
+		if err != nil {
+			panic(err)
 		}
 	}
 }
+
+func printEntropy(stdout io.Writer, entropy float64) error {
+	if os.Getenv("REPASSGEN_FLOAT_ENTROPY") == "true" {
+		_, err := fmt.Fprintf(stdout, "Entropy of pattern: %.2f bits\n", entropy)
+		return err
+	}
+	_, err := fmt.Fprintf(stdout, "Entropy of pattern: %d bits\n", int(entropy))
+	return err
+}
@@ -2,8 +2,13 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	passgen "github.com/ilius/repassgen/lib"
 )
 
 func TestMainFunc(t *testing.T) {
@@ -31,3 +36,348 @@ func TestMainFunc(t *testing.T) {
 
 	Main(stdout, []string{"repassgen", "[a-z]{6}", "-entropy"})
 }
+
+func TestMainFuncCount(t *testing.T) {
+	t.Setenv("REPASSGEN_FLOAT_ENTROPY", "")
+	stdout := bytes.NewBuffer(nil)
+	Main(stdout, []string{"repassgen", "-n", "5", "[a-z]{6}"})
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %#v", stdout.String())
+	}
+	for _, line := range lines {
+		if len(line) != 6 {
+			t.Errorf("bad password %#v", line)
+		}
+	}
+
+	stdout.Reset()
+	Main(stdout, []string{"repassgen", "-n", "3", "-null", "-entropy", "[a-z]{6}"})
+	parts := strings.Split(stdout.String(), "\x00")
+	if len(parts) != 4 {
+		t.Fatalf("expected 3 NUL-separated passwords, got %#v", stdout.String())
+	}
+	if parts[3] != "Entropy of pattern: 28 bits\n" {
+		t.Errorf("bad entropy line %#v", parts[3])
+	}
+}
+
+func TestMainFuncExplain(t *testing.T) {
+	stdout := bytes.NewBuffer(nil)
+	Main(stdout, []string{"repassgen", "-explain", "[a-z]{6}"})
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("unexpected output: %#v", stdout.String())
+	}
+	if lines[0] != `sequence "[a-z]{6}": 28.20 bits` {
+		t.Errorf("unexpected line: %#v", lines[0])
+	}
+
+	t.Setenv("REPASSGEN_FLOAT_ENTROPY", "")
+	stdout = bytes.NewBuffer(nil)
+	Main(stdout, []string{"repassgen", "-entropy", "-explain", "[a-z]{6}"})
+	lines = strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("unexpected output: %#v", stdout.String())
+	}
+	if lines[0] != "Entropy of pattern: 28 bits" {
+		t.Errorf("unexpected line: %#v", lines[0])
+	}
+	if lines[1] != `sequence "[a-z]{6}": 28.20 bits` {
+		t.Errorf("unexpected line: %#v", lines[1])
+	}
+
+	stdout = bytes.NewBuffer(nil)
+	Main(stdout, []string{"repassgen", "-entropy", "-explain=json", "[a-z]{6}"})
+	node := map[string]any{}
+	err := json.Unmarshal(stdout.Bytes(), &node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node["kind"] != "sequence" {
+		t.Errorf("unexpected kind: %#v", node["kind"])
+	}
+}
+
+func TestMainFuncUniform(t *testing.T) {
+	stdout := bytes.NewBuffer(nil)
+	Main(stdout, []string{"repassgen", "-uniform", "-n", "20", "[a-z]{3,5}"})
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 20 {
+		t.Fatalf("unexpected output: %#v", stdout.String())
+	}
+	for _, line := range lines {
+		if len(line) < 3 || len(line) > 5 {
+			t.Errorf("unexpected password: %#v", line)
+		}
+	}
+}
+
+func TestMainFuncClass(t *testing.T) {
+	// classes are not registered globally, so Main can be called again
+	for range 2 {
+		stdout := bytes.NewBuffer(nil)
+		Main(stdout, []string{
+			"repassgen",
+			"-class", "mainsafe=abc",
+			"-class", "mainsym=#",
+			"-n", "10",
+			"[:mainsafe:]{5}[:mainsym::digit:]",
+		})
+		lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+		if len(lines) != 10 {
+			t.Fatalf("unexpected output: %#v", stdout.String())
+		}
+		for _, line := range lines {
+			if len(line) != 6 || strings.Trim(line[:5], "abc") != "" ||
+				!strings.ContainsAny(line[5:], "#0123456789") {
+				t.Errorf("unexpected password: %#v", line)
+			}
+		}
+	}
+	stdout := bytes.NewBuffer(nil)
+	Main(stdout, []string{"repassgen", "-class", "mainab=abba", "-universe", "mainab", "[^a]{4}"})
+	if stdout.String() != "bbbb\n" {
+		t.Errorf("unexpected output: %#v", stdout.String())
+	}
+}
+
+func TestMainFuncUniverse(t *testing.T) {
+	stdout := bytes.NewBuffer(nil)
+	Main(stdout, []string{"repassgen", "-universe", "xdigit", "-n", "10", "[^0-9a-fA-E]{4}"})
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("unexpected output: %#v", stdout.String())
+	}
+	for _, line := range lines {
+		if line != "FFFF" {
+			t.Errorf("unexpected password: %#v", line)
+		}
+	}
+}
+
+func TestMainFuncExclude(t *testing.T) {
+	stdout := bytes.NewBuffer(nil)
+	Main(stdout, []string{"repassgen", "-unambiguous", "-exclude", "xyz", "-n", "10", "[:alnum:]{20}$byte()"})
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("unexpected output: %#v", stdout.String())
+	}
+	for _, line := range lines {
+		if len(line) != 22 || strings.ContainsAny(line, "0O1lIxyz") {
+			t.Errorf("unexpected password: %#v", line)
+		}
+	}
+}
+
+func TestMainFuncFileDir(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "codenames.txt"), []byte("falcon\nheron\nfalcon\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := bytes.NewBuffer(nil)
+	Main(stdout, []string{"repassgen", "-file-dir", dir, "-n", "10", "$line(codenames.txt)-$wordfile(codenames.txt,2,.)"})
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if len(lines) != 10 {
+		t.Fatalf("unexpected output: %#v", stdout.String())
+	}
+	for _, line := range lines {
+		name, words, _ := strings.Cut(line, "-")
+		if name != "falcon" && name != "heron" || strings.Trim(words, "falconher.") != "" {
+			t.Errorf("unexpected password: %#v", line)
+		}
+	}
+}
+
+func TestMainFuncConfig(t *testing.T) {
+	t.Setenv("REPASSGEN_FLOAT_ENTROPY", "")
+	confPath := filepath.Join(t.TempDir(), "patterns.toml")
+	err := os.WriteFile(confPath, []byte(`
+[patterns.mainwifi]
+pattern = "[:alnum:]{20}"
+description = "Wi-Fi key"
+min_entropy = 100
+
+[patterns.maindb]
+pattern = "$use(mainwifi)-[0-9]{4}"
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	{
+		stdout := bytes.NewBuffer(nil)
+		Main(stdout, []string{"repassgen", "-config", confPath, "-patterns"})
+		expected := "@maindb\t$use(mainwifi)-[0-9]{4}\n" +
+			"@mainwifi\t[:alnum:]{20}\tWi-Fi key\n"
+		if stdout.String() != expected {
+			t.Errorf("unexpected output: %#v", stdout.String())
+		}
+	}
+	{
+		stdout := bytes.NewBuffer(nil)
+		Main(stdout, []string{"repassgen", "-config", confPath, "-entropy", "@maindb"})
+		lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+		if len(lines) != 2 || len(lines[0]) != 25 || lines[0][20] != '-' {
+			t.Fatalf("unexpected output: %#v", stdout.String())
+		}
+		if lines[1] != "Entropy of pattern: 132 bits" {
+			t.Errorf("unexpected line: %#v", lines[1])
+		}
+	}
+	{
+		stdout := bytes.NewBuffer(nil)
+		Main(stdout, []string{"repassgen", "-config", confPath, `\@[a-z]{3}`})
+		if len(stdout.String()) != 5 || stdout.String()[0] != '@' {
+			t.Errorf("unexpected output: %#v", stdout.String())
+		}
+	}
+}
+
+func TestConfigResolvePattern(t *testing.T) {
+	conf := &Config{Patterns: map[string]*NamedPattern{
+		"weak": {Pattern: "[0-9]{4}", MinEntropy: 20},
+	}}
+	pattern, np, err := conf.resolvePattern("@weak")
+	if err != nil || pattern != "[0-9]{4}" || np == nil {
+		t.Fatalf("unexpected result: %#v, %#v, %v", pattern, np, err)
+	}
+	p, err := passgen.Compile([]rune(pattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = np.checkEntropy(p)
+	if err == nil || err.Error() != "entropy of pattern is 13.29 bits, less than minimum of 20 bits" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, _, err = conf.resolvePattern("@none")
+	if err == nil || err.Error() != `unknown pattern name "none"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+	pattern, np, err = conf.resolvePattern("[a-z]")
+	if err != nil || pattern != "[a-z]" || np != nil {
+		t.Errorf("unexpected result: %#v, %#v, %v", pattern, np, err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	confPath := filepath.Join(dir, "patterns.toml")
+	err := os.WriteFile(confPath, []byte(`
+[patterns.loadgood]
+pattern = "[a-z]{4}"
+
+[patterns.loadtypo]
+pattern = "$use(loadgood)[:alnun:]{4}"
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadConfig(confPath, true, passgen.GenerateInput{})
+	expected := confPath + `: pattern "loadtypo": value error near index 21: invalid character class "alnun"`
+	if err == nil || err.Error() != expected {
+		t.Errorf("unexpected error: %v", err)
+	}
+	conf, err := loadConfig(filepath.Join(dir, "missing.toml"), false, passgen.GenerateInput{})
+	if err != nil || len(conf.Patterns) != 0 {
+		t.Errorf("unexpected result: %#v, %v", conf, err)
+	}
+	for arg, expected := range map[string]bool{
+		"@wifi":            true,
+		"$use(wifi)-[0-9]": true,
+		"[a-z]{8}":         false,
+		`\@[a-z]{3}`:       false,
+		"":                 false,
+	} {
+		if usesConfig(arg) != expected {
+			t.Errorf("usesConfig(%#v) != %v", arg, expected)
+		}
+	}
+}
+
+func TestMainFuncPolicy(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "vendor.json")
+	err := os.WriteFile(jsonPath, []byte(`{
+	"min_length": 12,
+	"max_length": 14,
+	"require": ["[:upper:]", "[:digit:]"],
+	"forbidden": "<>&",
+	"max_repeat": 1
+}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tomlPath := filepath.Join(dir, "vendor.toml")
+	err = os.WriteFile(tomlPath, []byte(`
+min_length = 12
+max_length = 14
+require = ["[:upper:]", "[:digit:]"]
+forbidden = "<>&"
+max_repeat = 1
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, policyPath := range []string{jsonPath, tomlPath} {
+		policy, err := loadPolicy(policyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout := bytes.NewBuffer(nil)
+		Main(stdout, []string{"repassgen", "-policy", policyPath, "-n", "20"})
+		lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+		if len(lines) != 20 {
+			t.Fatalf("unexpected output: %#v", stdout.String())
+		}
+		for _, line := range lines {
+			if len(line) != 14 {
+				t.Errorf("unexpected password: %#v", line)
+			}
+			err := policy.CheckPassword([]rune(line))
+			if err != nil {
+				t.Errorf("password %#v: %v", line, err)
+			}
+		}
+		stdout = bytes.NewBuffer(nil)
+		Main(stdout, []string{"repassgen", "-policy", policyPath, "([A-Z][0-9]){6}"})
+		if len(stdout.String()) != 13 {
+			t.Errorf("unexpected output: %#v", stdout.String())
+		}
+		// -explain shows the same entropy as -entropy
+		stdout = bytes.NewBuffer(nil)
+		Main(stdout, []string{"repassgen", "-policy", policyPath, "-entropy"})
+		entropyLine := strings.Split(stdout.String(), "\n")[1]
+		stdout = bytes.NewBuffer(nil)
+		Main(stdout, []string{"repassgen", "-policy", policyPath, "-entropy", "-explain"})
+		lines = strings.Split(stdout.String(), "\n")
+		if lines[0] != entropyLine {
+			t.Errorf("unexpected entropy: %#v != %#v", lines[0], entropyLine)
+		}
+		if !strings.HasPrefix(lines[1], "policy ") {
+			t.Errorf("unexpected explain output: %#v", stdout.String())
+		}
+	}
+}
+
+func TestLoadPolicyUnknownRule(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "vendor.json")
+	err := os.WriteFile(jsonPath, []byte(`{"min_lenght": 12}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadPolicy(jsonPath)
+	if err == nil || err.Error() != jsonPath+`: json: unknown field "min_lenght"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+	tomlPath := filepath.Join(dir, "vendor.toml")
+	err = os.WriteFile(tomlPath, []byte("min_lenght = 12\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadPolicy(tomlPath)
+	if err == nil || err.Error() != tomlPath+`: unknown policy rule "min_lenght"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	passgen "github.com/ilius/repassgen/lib"
+)
+
+// loadPolicy reads and parses a policy file, for example:
+//
+//	{
+//		"min_length": 12,
+//		"max_length": 20,
+//		"require": ["[:upper:]", "[:lower:]", "[:digit:]"],
+//		"forbidden": "<>&",
+//		"max_repeat": 2
+//	}
+//
+// file is parsed as TOML if its extension is .toml, and as JSON otherwise
+// unknown keys are reported as error, to catch typos in rule names
+func loadPolicy(path string) (*passgen.Policy, error) {
+	policy := &passgen.Policy{}
+	if strings.ToLower(filepath.Ext(path)) == ".toml" {
+		meta, err := toml.DecodeFile(path, policy)
+		if err != nil {
+			return nil, err
+		}
+		undecoded := meta.Undecoded()
+		if len(undecoded) > 0 {
+			return nil, fmt.Errorf("%s: unknown policy rule %#v", path, undecoded[0].String())
+		}
+		return policy, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(policy)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// generateWithPolicy generates count passwords that comply with policy
+func generateWithPolicy(p *passgen.Pattern, policy *passgen.Policy, count int) ([]*passgen.GenerateOutput, error) {
+	outList := make([]*passgen.GenerateOutput, count)
+	for i := range count {
+		out, err := p.GeneratePolicy(policy)
+		if err != nil {
+			return nil, err
+		}
+		outList[i] = out
+	}
+	return outList, nil
+}